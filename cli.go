@@ -0,0 +1,491 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// dataFlags are the flags shared by every command that reads a csv
+type dataFlags struct {
+	inputFile *string
+	columns   *string
+	missing   *string
+	fill      *float64
+	report    *string
+	weight    *string
+}
+
+// newDataFlags registers the data loading flags on fs
+func newDataFlags(fs *flag.FlagSet) *dataFlags {
+	return &dataFlags{
+		inputFile: fs.String("i", "regression_test.csv", "input path to csv data source"),
+		columns:   fs.String("c", "0,1", "specify the columns that you want to read in from the csv by index or header name in the format: x,y or x1,x2,...:y"),
+		missing:   fs.String("missing", "drop", "how missing or non numeric values are handled, a policy for every column or column=policy entries separated by commas. Policies are drop, fail, impute-mean, impute-median and impute-constant"),
+		fill:      fs.Float64("fill", 0, "the value used by the impute-constant missing value policy"),
+		report:    fs.String("report", "text", "write the data quality report to stderr as text or json, or none to skip it"),
+		weight:    fs.String("w", "", "the column, by index or header name, of non negative row weights for weighted least squares"),
+	}
+}
+
+// dataset is the data read from a csv by the shared loading layer
+type dataset struct {
+	X [][]float64
+	Y []float64
+	// W holds the weight of each row, nil when the rows are unweighted
+	W       []float64
+	Head    []string
+	Quality DataQuality
+	// Hash is the sha256 of the bytes of the csv
+	Hash hash.Hash
+}
+
+// open validates the flags and opens the csv, returning the reader along with
+// the parsed column and missing value options. The hash is fed the bytes of
+// the csv as they are read
+func (d *dataFlags) open() (*os.File, *csv.Reader, []string, string, missingSpec, hash.Hash) {
+	// parse columns of interest
+	xstr, ystr, err := parseColumns(*d.columns)
+	if err != nil {
+		log.Fatal(err)
+	}
+	spec, err := parseMissing(*d.missing, *d.fill)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *d.report != "text" && *d.report != "json" && *d.report != "none" {
+		log.Fatalf("unknown report format %q, must be text, json or none", *d.report)
+	}
+	// open data file
+	f, err := os.Open(*d.inputFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// hash the bytes read to fingerprint the training data of saved models
+	dataHash := newDataHash()
+	return f, csv.NewReader(io.TeeReader(f, dataHash)), xstr, ystr, spec, dataHash
+}
+
+// load reads the columns of interest from the csv into memory and writes the
+// data quality report
+func (d *dataFlags) load() *dataset {
+	f, reader, xstr, ystr, spec, dataHash := d.open()
+	defer f.Close()
+	X, Y, W, head, quality, err := readInValues(xstr, ystr, *d.weight, spec, reader)
+	if err != nil {
+		log.Fatalf("%s: %v", *d.inputFile, err)
+	}
+	d.writeQuality(quality)
+	if len(X) == 0 {
+		log.Fatalf("no usable rows read from %s", *d.inputFile)
+	}
+	if err := checkWeights(W); err != nil {
+		log.Fatalf("%s: %v", *d.inputFile, err)
+	}
+	return &dataset{X: X, Y: Y, W: W, Head: head, Quality: quality, Hash: dataHash}
+}
+
+// unweighted exits when -w was given to a command that only fits unweighted
+// models
+func (d *dataFlags) unweighted(command string) {
+	if *d.weight != "" {
+		log.Fatalf("%s does not support row weights, remove -w", command)
+	}
+}
+
+// writeQuality writes the data quality report to stderr in the format given by
+// the -report flag
+func (d *dataFlags) writeQuality(quality DataQuality) {
+	switch *d.report {
+	case "text":
+		quality.writeText(os.Stderr)
+	case "json":
+		if err := quality.writeJSON(os.Stderr); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// defaultOutput returns the output file, or the input file with a .png
+// extension when it was not given
+func (d *dataFlags) defaultOutput(outputFile string) string {
+	if outputFile != "" {
+		return outputFile
+	}
+	return strings.TrimSuffix(*d.inputFile, filepath.Ext(*d.inputFile)) + ".png"
+}
+
+// solverFlags are the flags shared by every command that fits a model
+type solverFlags struct {
+	name         *string
+	epsilon      *float64
+	maxIter      *int
+	lineSearch   *string
+	learningRate *float64
+	batchSize    *int
+	epochs       *int
+	seed         *int64
+	alpha        *float64
+	l1Ratio      *float64
+	robust       *string
+	threshold    *float64
+	ransacCutoff *float64
+	maxTrials    *int
+	quantile     *string
+	errorRatio   *float64
+	family       *string
+}
+
+// newSolverFlags registers the solver flags on fs
+func newSolverFlags(fs *flag.FlagSet) *solverFlags {
+	return &solverFlags{
+		name:         fs.String("solver", "newton", "the method used to fit the regression: newton, qr, gd, sgd, minibatch, momentum, adam, or theil-sen, ransac, deming and tls for a single predictor"),
+		epsilon:      fs.Float64("e", .001, "define the value of epsilon"),
+		maxIter:      fs.Int("max-iter", 100, "the maximum number of iterations of the newton solver, or sweeps of coordinate descent"),
+		lineSearch:   fs.String("line-search", "armijo", "the line search used by the newton solver: none, armijo or wolfe"),
+		learningRate: fs.Float64("lr", .1, "the learning rate of the gradient descent solvers"),
+		batchSize:    fs.Int("batch", 0, "the number of rows per update of the minibatch, momentum and adam solvers. Zero uses every row for momentum and adam and 32 rows for minibatch"),
+		epochs:       fs.Int("epochs", 1000, "the maximum number of passes over the data made by the gradient descent solvers. With a constant learning rate sgd and minibatch never settle and always make every pass"),
+		seed:         fs.Int64("seed", 1, "the seed used to shuffle rows, between epochs of the gradient descent solvers and into folds for cv"),
+		alpha:        fs.Float64("alpha", 0, "the strength of the penalty on the coefficients of the standardized predictors, zero for ordinary least squares"),
+		l1Ratio:      fs.Float64("l1-ratio", 0, "the share of the penalty given to the l1 norm: 0 for ridge, 1 for lasso and anything between for elastic net"),
+		robust:       fs.String("robust", "none", "fit a robust M-estimator that down-weights outliers by iteratively reweighted least squares: none, huber or bisquare"),
+		threshold:    fs.Float64("threshold", 0, "the residual, in units of the MAD scale, beyond which the robust loss down-weights rows. Zero uses 1.345 for huber and 4.685 for bisquare"),
+		ransacCutoff: fs.Float64("ransac-threshold", 0, "the largest absolute residual of an inlier of the ransac solver. Zero uses 2.5 robust standard deviations of the residuals from a theil-sen fit"),
+		maxTrials:    fs.Int("max-trials", 100, "the number of random lines the ransac solver tries"),
+		family:       fs.String("family", "gaussian", "the distribution of the response: gaussian for a linear model, or binomial for a logistic regression of a 0/1 response fitted with newtons method"),
+		errorRatio:   fs.Float64("error-ratio", 1, "the variance of the errors of the response over that of the predictor assumed by the deming solver"),
+		quantile:     fs.String("quantile", "", "fit quantile regression of these conditional quantiles of the response, one or several comma separated values between 0 and 1 such as 0.1,0.5,0.9"),
+	}
+}
+
+// checkNewton returns an error unless the newton settings bound the number of
+// iterations and leave a step magnitude that can pass the convergence test
+func (s *solverFlags) checkNewton() error {
+	if *s.maxIter < 1 {
+		return fmt.Errorf("max iterations %d must be at least one", *s.maxIter)
+	}
+	if *s.epsilon < 0 {
+		return fmt.Errorf("epsilon %g must not be negative", *s.epsilon)
+	}
+	return nil
+}
+
+// newtonConflicts returns the -solver and -alpha settings that ask for
+// something other than the plain newton solver, naming them in the error of a
+// fit that only the newton solver makes
+func (s *solverFlags) newtonConflicts() []string {
+	var conflicts []string
+	if *s.name != "newton" {
+		conflicts = append(conflicts, "-solver "+*s.name)
+	}
+	if *s.alpha > 0 {
+		conflicts = append(conflicts, fmt.Sprintf("-alpha %g", *s.alpha))
+	}
+	return conflicts
+}
+
+// solver returns the solver named by the -solver flag configured by the
+// other solver flags
+func (s *solverFlags) solver() (Solver, error) {
+	if err := s.checkNewton(); err != nil {
+		return nil, err
+	}
+	gd := gradientSolver{
+		LearningRate: *s.learningRate,
+		Epsilon:      *s.epsilon,
+		MaxEpochs:    *s.epochs,
+		BatchSize:    *s.batchSize,
+		Seed:         *s.seed,
+	}
+	if *s.alpha < 0 {
+		return nil, fmt.Errorf("alpha %g must not be negative", *s.alpha)
+	}
+	loss, err := parseRobustLoss(*s.robust)
+	if err != nil {
+		return nil, err
+	}
+	switch *s.family {
+	case "gaussian":
+	case "binomial":
+		// the log loss is minimised with the same newton loop as least
+		// squares
+		conflicts := s.newtonConflicts()
+		if *s.robust != "none" {
+			conflicts = append(conflicts, "-robust "+*s.robust)
+		}
+		if *s.quantile != "" {
+			conflicts = append(conflicts, "-quantile "+*s.quantile)
+		}
+		if len(conflicts) > 0 {
+			return nil, fmt.Errorf("-family binomial is fitted with the newton solver and cannot be combined with %s", strings.Join(conflicts, " or "))
+		}
+		ls, err := parseLineSearch(*s.lineSearch)
+		if err != nil {
+			return nil, err
+		}
+		return logisticSolver{Epsilon: *s.epsilon, MaxIter: *s.maxIter, LineSearch: ls}, nil
+	default:
+		return nil, fmt.Errorf("unknown family %q, must be gaussian or binomial", *s.family)
+	}
+	if *s.quantile != "" {
+		return s.quantileSolver()
+	}
+	if loss != noRobustLoss {
+		// each reweighting is a weighted least squares fit with newtons
+		// method
+		if conflicts := s.newtonConflicts(); len(conflicts) > 0 {
+			return nil, fmt.Errorf("-robust %s is fitted with the newton solver and cannot be combined with %s", loss, strings.Join(conflicts, " or "))
+		}
+		if *s.threshold < 0 {
+			return nil, fmt.Errorf("robust threshold %g must not be negative", *s.threshold)
+		}
+		ls, err := parseLineSearch(*s.lineSearch)
+		if err != nil {
+			return nil, err
+		}
+		return robustSolver{Loss: loss, Threshold: *s.threshold, Epsilon: *s.epsilon, MaxIter: *s.maxIter, LineSearch: ls}, nil
+	}
+	if *s.alpha > 0 {
+		// ridge is solved with newtons method and the l1 penalty with
+		// coordinate descent, so only the newton settings apply
+		if *s.name != "newton" {
+			return nil, fmt.Errorf("the %s solver cannot fit a penalized regression, use -solver newton with -alpha", *s.name)
+		}
+		return s.regularized()
+	}
+	switch *s.name {
+	case "minibatch", "momentum", "adam":
+		if *s.batchSize < 0 {
+			return nil, fmt.Errorf("batch size %d must not be negative", *s.batchSize)
+		}
+	}
+	switch *s.name {
+	case "newton":
+		ls, err := parseLineSearch(*s.lineSearch)
+		if err != nil {
+			return nil, err
+		}
+		return newtonSolver{Epsilon: *s.epsilon, MaxIter: *s.maxIter, LineSearch: ls}, nil
+	case "qr":
+		return qrSolver{}, nil
+	case "gd":
+		gd.BatchSize = 0
+		return gd, nil
+	case "sgd":
+		gd.BatchSize = 1
+		return gd, nil
+	case "minibatch":
+		if gd.BatchSize == 0 {
+			gd.BatchSize = defaultMiniBatch
+		}
+		return gd, nil
+	case "momentum":
+		gd.Optimizer = momentumUpdate
+		return gd, nil
+	case "adam":
+		gd.Optimizer = adamUpdate
+		return gd, nil
+	case "theil-sen":
+		return theilSenSolver{}, nil
+	case "ransac":
+		if *s.maxTrials < 1 {
+			return nil, fmt.Errorf("max trials %d must be at least one", *s.maxTrials)
+		}
+		return ransacSolver{Threshold: *s.ransacCutoff, MaxTrials: *s.maxTrials, Seed: *s.seed}, nil
+	case "deming":
+		if *s.errorRatio <= 0 {
+			return nil, fmt.Errorf("error ratio %g must be positive", *s.errorRatio)
+		}
+		return demingSolver{Ratio: *s.errorRatio}, nil
+	case "tls":
+		// total least squares is deming regression with equal error
+		// variances
+		return demingSolver{Ratio: 1}, nil
+	}
+	return nil, fmt.Errorf("unknown solver %q, must be one of newton, qr, gd, sgd, minibatch, momentum, adam, theil-sen, ransac, deming or tls", *s.name)
+}
+
+// quantiles returns the quantiles of the -quantile flag, nil when it was not
+// given
+func (s *solverFlags) quantiles() ([]float64, error) {
+	if *s.quantile == "" {
+		return nil, nil
+	}
+	return parseQuantiles(*s.quantile)
+}
+
+// quantileSolver returns the solver of the single quantile of the -quantile
+// flag
+func (s *solverFlags) quantileSolver() (Solver, error) {
+	taus, err := s.quantiles()
+	if err != nil {
+		return nil, err
+	}
+	if len(taus) != 1 {
+		return nil, fmt.Errorf("%d quantiles were given but only fit and plot draw several, pass a single -quantile", len(taus))
+	}
+	return s.quantileSolverAt(taus[0])
+}
+
+// quantileSolverAt returns the solver of the quantile tau configured by the
+// newton flags
+func (s *solverFlags) quantileSolverAt(tau float64) (quantileSolver, error) {
+	if err := s.checkNewton(); err != nil {
+		return quantileSolver{}, err
+	}
+	// each majorization is a weighted least squares fit with newtons method
+	if *s.name != "newton" || *s.alpha > 0 || *s.robust != "none" {
+		return quantileSolver{}, fmt.Errorf("-quantile is fitted with the newton solver and cannot be combined with -solver %s, -alpha or -robust", *s.name)
+	}
+	ls, err := parseLineSearch(*s.lineSearch)
+	if err != nil {
+		return quantileSolver{}, err
+	}
+	return quantileSolver{Tau: tau, Epsilon: *s.epsilon, MaxIter: *s.maxIter, LineSearch: ls}, nil
+}
+
+// regularized returns the elastic net solver configured by the solver flags
+// whatever the value of -alpha
+func (s *solverFlags) regularized() (regularizedSolver, error) {
+	if err := s.checkNewton(); err != nil {
+		return regularizedSolver{}, err
+	}
+	if *s.l1Ratio < 0 || *s.l1Ratio > 1 {
+		return regularizedSolver{}, fmt.Errorf("l1 ratio %g must be between 0 and 1", *s.l1Ratio)
+	}
+	ls, err := parseLineSearch(*s.lineSearch)
+	if err != nil {
+		return regularizedSolver{}, err
+	}
+	return regularizedSolver{
+		Alpha:      *s.alpha,
+		L1Ratio:    *s.l1Ratio,
+		Epsilon:    *s.epsilon,
+		MaxIter:    *s.maxIter,
+		LineSearch: ls,
+	}, nil
+}
+
+// label names the fitting method for output and saved models, which is the
+// solver unless the fit is penalized
+func (s *solverFlags) label() string {
+	switch {
+	case *s.family == "binomial":
+		return "logistic"
+	case *s.quantile != "":
+		return "quantile"
+	case *s.robust != "none":
+		return *s.robust
+	case *s.alpha == 0:
+		return *s.name
+	case *s.l1Ratio == 0:
+		return "ridge"
+	case *s.l1Ratio == 1:
+		return "lasso"
+	}
+	return "elastic-net"
+}
+
+// binomial reports whether the flags select a logistic regression
+func (s *solverFlags) binomial() bool {
+	return *s.family == "binomial"
+}
+
+// checkLevel exits when a confidence level is outside of (0, 1)
+func checkLevel(level float64) {
+	if level <= 0 || level >= 1 {
+		log.Fatalf("confidence level %g must be between 0 and 1", level)
+	}
+}
+
+// featureFlags are the flags that expand the predictors into the features of
+// the model
+type featureFlags struct {
+	degree *int
+	basis  *string
+}
+
+// newFeatureFlags registers the feature flags on fs
+func newFeatureFlags(fs *flag.FlagSet) *featureFlags {
+	return &featureFlags{
+		degree: fs.Int("degree", 1, "expand the single predictor into polynomial features up to this degree"),
+		basis:  fs.String("basis", "orthogonal", "the polynomial basis used when -degree is above one: raw powers or orthogonal polynomials"),
+	}
+}
+
+// expand fits the polynomial basis to the single predictor of X and returns it
+// along with the expanded rows and head. When the degree is one the basis is
+// nil and X and head are returned unchanged
+func (f *featureFlags) expand(X [][]float64, head []string) (*polyBasis, [][]float64, []string, error) {
+	if *f.degree == 1 {
+		return nil, X, head, nil
+	}
+	if *f.basis != "raw" && *f.basis != "orthogonal" {
+		return nil, nil, nil, fmt.Errorf("unknown polynomial basis %q, must be raw or orthogonal", *f.basis)
+	}
+	if len(X[0]) != 1 {
+		return nil, nil, nil, fmt.Errorf("-degree expands a single predictor but %d were given", len(X[0]))
+	}
+	basis, err := newPolyBasis(head[0], column(X, 0), *f.degree, *f.basis == "orthogonal")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return basis, basis.expandAll(X), append(basis.names(), head[len(head)-1]), nil
+}
+
+// cvFlags are the flags that split the rows for cross-validation
+type cvFlags struct {
+	mode     *string
+	k        *int
+	repeats  *int
+	testSize *float64
+}
+
+// newCVFlags registers the cross-validation flags on fs
+func newCVFlags(fs *flag.FlagSet) *cvFlags {
+	return &cvFlags{
+		mode:     fs.String("split", "kfold", "how the rows are split: kfold, loo for leave-one-out, or holdout for a single train and test split"),
+		k:        fs.Int("k", 5, "the number of folds of kfold"),
+		repeats:  fs.Int("repeats", 1, "the number of times kfold is repeated with a fresh shuffle"),
+		testSize: fs.Float64("test-size", .25, "the fraction of the rows held out by holdout"),
+	}
+}
+
+// splits returns the splits of n rows named by -split, shuffled by a generator
+// seeded with seed
+func (c *cvFlags) splits(n int, seed int64) ([]split, error) {
+	rng := rand.New(rand.NewSource(seed))
+	switch *c.mode {
+	case "kfold":
+		if *c.k < 2 || *c.k > n {
+			return nil, fmt.Errorf("the number of folds must be between 2 and the %d rows read, got %d", n, *c.k)
+		}
+		if *c.repeats < 1 {
+			return nil, fmt.Errorf("the number of repeats must be positive, got %d", *c.repeats)
+		}
+		return kFoldSplits(n, *c.k, *c.repeats, rng), nil
+	case "loo":
+		if n < 3 {
+			return nil, fmt.Errorf("leave-one-out needs at least 3 rows, got %d", n)
+		}
+		return kFoldSplits(n, n, 1, rng), nil
+	case "holdout":
+		if *c.testSize <= 0 || *c.testSize >= 1 {
+			return nil, fmt.Errorf("test size %g must be between 0 and 1", *c.testSize)
+		}
+		s, err := holdoutSplit(n, *c.testSize, rng)
+		if err != nil {
+			return nil, err
+		}
+		return []split{s}, nil
+	}
+	return nil, fmt.Errorf("unknown split %q, must be kfold, loo or holdout", *c.mode)
+}
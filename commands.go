@@ -0,0 +1,811 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// runFit implements the fit subcommand, fitting a regression and printing its
+// summary with optional plots and a saved model
+func runFit(args []string) {
+	fs := flag.NewFlagSet("fit", flag.ExitOnError)
+	data := newDataFlags(fs)
+	solverOpts := newSolverFlags(fs)
+	featureOpts := newFeatureFlags(fs)
+	iterationsVisible := fs.Bool("v", true, "make each iteration of the solver visible")
+	outputFile := fs.String("o", "", "the name of the file that the line is outputted to, defaults to the input name with a .png extension. Must be *.png,*jpeg. *bmp")
+	plotVisible := fs.Bool("plot", true, "write the regression plot to the output file")
+	diagnosticsVisible := fs.Bool("diagnostics", false, "write residual diagnostic plots next to the output file with a _diagnostics.png suffix")
+	stream := fs.Bool("stream", false, "fit in a single pass over the csv in constant memory, plotting a random sample of the rows")
+	sampleSize := fs.Int("sample", 1000, "the number of rows sampled for the plot when streaming")
+	saveFile := fs.String("save", "", "write the fitted model to this json file for use with the predict subcommand")
+	resample := fs.String("resample", "none", "also estimate the standard errors and confidence intervals by refitting to resampled rows: none, bootstrap or jackknife")
+	resamples := fs.Int("resamples", 1000, "the number of bootstrap resamples")
+	weightsFile := fs.String("weights-out", "", "write the final weight of every row of a -robust fit, or 1 for the inliers and 0 for the outliers of ransac, to this csv file")
+	cutoff := fs.Float64("cutoff", .5, "the probability at or above which a -family binomial fit predicts a 1 for its accuracy and confusion matrix")
+	describe := fs.Bool("d", false, "output the first five elements of the data used and along with header information")
+	ciLevel := fs.Float64("ci", .95, "the confidence level of the coefficient confidence intervals")
+	format := fs.String("format", "text", "the format of the results on stdout: text, json for a single document with every result, or csv for the coefficient table. Human readable output goes to stderr with json and csv")
+	fs.Parse(args)
+	checkLevel(*ciLevel)
+	if *cutoff <= 0 || *cutoff >= 1 {
+		log.Fatalf("cutoff %g must be between 0 and 1", *cutoff)
+	}
+	if solverOpts.binomial() && *diagnosticsVisible {
+		log.Fatal("-diagnostics plots the residuals of least squares and cannot be combined with -family binomial")
+	}
+	if *stream && *plotVisible && *sampleSize < 1 {
+		log.Fatalf("the streaming plot needs a -sample of at least one row, got %d, or -plot=false", *sampleSize)
+	}
+	if err := checkFormat(*format); err != nil {
+		log.Fatal(err)
+	}
+	if err := checkResample(*resample, *resamples); err != nil {
+		log.Fatal(err)
+	}
+	taus, err := solverOpts.quantiles()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(taus) > 1 && (*saveFile != "" || *diagnosticsVisible || *weightsFile != "" || *resample != "none") {
+		log.Fatal("-save, -diagnostics, -weights-out and -resample need a single -quantile")
+	}
+	*outputFile = data.defaultOutput(*outputFile)
+	// human readable output is kept off stdout when it carries a structured
+	// format
+	human := io.Writer(os.Stdout)
+	if *format != "text" {
+		human = os.Stderr
+	}
+	out := &fitOutput{Input: *data.inputFile, Solver: solverOpts.label(), Weights: *data.weight}
+
+	// X holds the features of the model and rawX the predictors they were
+	// expanded from
+	var X, rawX [][]float64
+	var Y, W []float64
+	var coef []float64
+	var b float64
+	var head []string
+	var basis *polyBasis
+	var summary FitSummary
+	var dataHash hash.Hash
+	// robustW holds the final weights of a robust fit, which the summary and
+	// plots use on top of any row weights in W
+	var robustW []float64
+	// inliers marks the rows the ransac solver fitted
+	var inliers []bool
+	// fitX, fitY and fitW are the rows the summary and diagnostics describe,
+	// which leave out the outliers of a ransac fit
+	var fitX [][]float64
+	var fitY, fitW []float64
+	if *stream {
+		if *featureOpts.degree != 1 {
+			log.Fatal("streaming fits a single pass over the raw columns and cannot be combined with -degree")
+		}
+		if *diagnosticsVisible {
+			log.Fatal("streaming keeps only a sample of the rows and cannot plot their residual diagnostics, remove -diagnostics")
+		}
+		if *describe {
+			log.Fatal("streaming does not hold the rows to describe, remove -d or use the describe subcommand")
+		}
+		if *solverOpts.alpha != 0 || *solverOpts.robust != "none" || taus != nil || *resample != "none" || solverOpts.binomial() {
+			log.Fatal("streaming fits ordinary least squares and cannot be combined with -alpha, -robust, -quantile, -resample or -family binomial")
+		}
+		data.unweighted("streaming")
+		// large inputs are fitted from sufficient statistics without holding
+		// the rows in memory
+		f, reader, xstr, ystr, spec, h := data.open()
+		defer f.Close()
+		fmt.Fprintln(human, "Starting Streaming Regression")
+		res, err := streamRegression(xstr, ystr, spec, reader, *sampleSize, *solverOpts.seed, *ciLevel)
+		if err != nil {
+			fatalSolverError(err)
+		}
+		data.writeQuality(res.Quality)
+		X, Y, coef, b, head, summary = res.SampleX, res.SampleY, res.Coef, res.Intercept, res.Head, res.Summary
+		rawX = X
+		dataHash, out.Quality, out.Solver = h, res.Quality, "stream"
+		fmt.Fprintf(human, "\nRegression Line: %s\n\n", equationString(coef, b, head))
+	} else {
+		ds := data.load()
+		rawX, Y, W, dataHash, out.Quality = ds.X, ds.Y, ds.W, ds.Hash, ds.Quality
+		if *describe {
+			fmt.Fprintln(human, "\n"+describeString(rawX, Y, ds.Head))
+		}
+		if basis, X, head, err = featureOpts.expand(rawX, ds.Head); err != nil {
+			log.Fatal(err)
+		}
+		if len(taus) > 1 {
+			// several quantiles have no single line or summary, only a
+			// line and goodness of fit for each
+			out.Quantiles = fitQuantiles(human, solverOpts, taus, basis, X, Y, W, head, *iterationsVisible)
+			out.Features, out.Response = head[:len(head)-1], head[len(head)-1]
+			if *plotVisible {
+				plotQuantiles(rawX, Y, out.Quantiles, head, basis, *outputFile)
+				out.Plots = append(out.Plots, *outputFile)
+			}
+			writeFitOutput(out, *format)
+			return
+		}
+
+		fmt.Fprintln(human, "Starting Regression")
+		result := fitWith(solverOpts, basis, X, Y, W)
+		if *iterationsVisible {
+			writeTrace(human, result.Trace)
+		}
+		if result.Condition != 0 {
+			fmt.Fprintf(human, "Condition Number: %.8f\n", result.Condition)
+		}
+		coef, b = result.Coef, result.Intercept
+		out.Trace, out.Condition = result.Trace, result.Condition
+		robustW, out.RobustWeights, out.RobustScale = result.Weights, result.Weights, result.Scale
+		inliers, out.Inliers = result.Inliers, result.Inliers
+		if *resample != "none" {
+			// refit the solver to resampled rows with their row weights
+			solver, err := solverOpts.solver()
+			if err != nil {
+				log.Fatal(err)
+			}
+			if out.Resampled, err = resampleInference(solver, X, Y, W, coef, b, head[:len(coef)], *resample, *resamples, *solverOpts.seed, *ciLevel); err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		// predictions of the regression equation for each row
+		yPred := make([]float64, len(X))
+		for i := range X {
+			yPred[i] = predict(coef, X[i], b)
+		}
+
+		if solverOpts.binomial() {
+			// the line is that of the log odds, which is judged by how well
+			// its probabilities classify the rows
+			c := newClassification(Y, predictProbabilities(X, coef, b), W, *cutoff)
+			fmt.Fprintf(human, "\nRegression Line (log odds): %s\n%s\n", equationString(coef, b, head), c)
+			out.Classification = &c
+		} else {
+			fmt.Fprintf(human, "\nRegression Line: %s\n", equationString(coef, b, head))
+			switch {
+			case W != nil && len(coef) == 1:
+				fmt.Fprintf(human, "Weighted Correlation Coefficient: %.8f\n", weightedCorrelation(column(X, 0), Y, W))
+			case W != nil:
+				fmt.Fprintf(human, "Weighted Multiple Correlation Coefficient: %.8f\n", weightedCorrelation(yPred, Y, W))
+			case len(coef) == 1:
+				fmt.Fprintf(human, "Correlation Coefficient: %.8f\n", correlationCoefficient(column(X, 0), Y))
+			default:
+				fmt.Fprintf(human, "Multiple Correlation Coefficient: %.8f\n", correlationCoefficient(yPred, Y))
+			}
+			if W != nil {
+				fmt.Fprintf(human, "Weighted MAE: %.8f\n\n", weightedMAE(Y, yPred, W))
+			} else {
+				fmt.Fprintf(human, "MAE: %.8f\n\n", calcMAE(Y, yPred))
+			}
+		}
+		if taus != nil {
+			fit := newQuantileFit(taus[0], X, Y, W, coef, b)
+			fmt.Fprintf(human, "Quantile: %g\nCheck Loss: %.8f\nPseudo R1: %.8f\n\n", fit.Tau, fit.CheckLoss, fit.PseudoR1)
+			out.Quantiles = []quantileFit{fit}
+		}
+
+		if robustW != nil {
+			writeRobustWeights(human, robustW, Y, yPred, result.Scale)
+		}
+		if inliers != nil {
+			writeInliers(human, inliers, Y, yPred, result.Scale)
+		}
+
+		// goodness of fit and inference for each coefficient
+		summary, fitX, fitY, fitW = summarizeFit(solverOpts, result, X, Y, W, head, *ciLevel)
+		if summary.Coefficients == nil {
+			log.Printf("skipping coefficient inference, the parameter covariance could not be estimated")
+		}
+		if *solverOpts.alpha > 0 {
+			fmt.Fprintln(human, "Note: the standard errors and tests below are those of least squares and ignore the shrinkage of the penalty")
+		}
+		if robustW != nil {
+			fmt.Fprintln(human, "Note: the summary below is weighted least squares with the final robust weights held fixed")
+		}
+		if inliers != nil {
+			fmt.Fprintln(human, "Note: the summary below is least squares on the inliers alone")
+		}
+		if taus != nil {
+			fmt.Fprintln(human, "Note: the standard errors and tests below are those of least squares and do not describe the quantile fit")
+		}
+		if *solverOpts.name == "deming" || *solverOpts.name == "tls" {
+			fmt.Fprintln(human, "Note: the standard errors and tests below are those of least squares and ignore the error in the predictor, -resample estimates ones that do not")
+		}
+	}
+	fmt.Fprintln(human, summary)
+	if res := out.Resampled; res != nil {
+		fmt.Fprintf(human, "Resampled Inference (%s, %d refits):\n%s\n", res.Method, res.Resamples, coefficientTable(res.Coefficients, *ciLevel))
+	}
+	out.Features, out.Response = head[:len(coef)], head[len(coef)]
+	out.Coef, out.Intercept, out.Summary = coef, b, summary
+
+	if *plotVisible {
+		if solverOpts.binomial() {
+			plotLogistic(rawX, Y, coef, b, head, basis, *solverOpts.seed, *outputFile)
+		} else {
+			plotFit(rawX, Y, coef, b, head, summary, basis, robustW, inliers, *outputFile)
+		}
+		out.Plots = append(out.Plots, *outputFile)
+	}
+	if *diagnosticsVisible {
+		out.Plots = append(out.Plots, writeDiagnostics(fitX, fitY, fitW, coef, b, summary, inliers, *outputFile))
+	}
+	if *weightsFile != "" {
+		weights := robustW
+		if inliers != nil {
+			weights = inlierWeights(inliers)
+		}
+		if weights == nil {
+			log.Fatal("-weights-out writes the weights of a -robust or ransac fit")
+		}
+		if err := saveRobustWeights(weights, Y, X, coef, b, *weightsFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *saveFile != "" {
+		m := newModel(coef, b, head, out.Solver, summary, dataHash)
+		m.Basis = basis
+		if solverOpts.binomial() {
+			m.Family = "binomial"
+		}
+		if err := saveModel(m, *saveFile); err != nil {
+			log.Fatal(err)
+		}
+		out.Model = *saveFile
+	}
+	writeFitOutput(out, *format)
+}
+
+// writeFitOutput writes the fit output to stdout in the structured format, if
+// any, given by the -format flag
+func writeFitOutput(out *fitOutput, format string) {
+	var err error
+	switch format {
+	case "json":
+		err = out.writeJSON(os.Stdout)
+	case "csv":
+		err = out.writeCSV(os.Stdout)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runPlot implements the plot subcommand, fitting a regression quietly and
+// writing the regression plot along with optional diagnostics
+func runPlot(args []string) {
+	fs := flag.NewFlagSet("plot", flag.ExitOnError)
+	data := newDataFlags(fs)
+	solverOpts := newSolverFlags(fs)
+	featureOpts := newFeatureFlags(fs)
+	outputFile := fs.String("o", "", "the name of the file that the line is outputted to, defaults to the input name with a .png extension. Must be *.png,*jpeg. *bmp")
+	diagnosticsVisible := fs.Bool("diagnostics", false, "write residual diagnostic plots next to the output file with a _diagnostics.png suffix")
+	ciLevel := fs.Float64("ci", .95, "the confidence level of the shaded interval bands")
+	fs.Parse(args)
+	checkLevel(*ciLevel)
+	*outputFile = data.defaultOutput(*outputFile)
+
+	taus, err := solverOpts.quantiles()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(taus) > 1 && *diagnosticsVisible {
+		log.Fatal("-diagnostics needs a single -quantile")
+	}
+	if solverOpts.binomial() && *diagnosticsVisible {
+		log.Fatal("-diagnostics plots the residuals of least squares and cannot be combined with -family binomial")
+	}
+
+	ds := data.load()
+	basis, X, head, err := featureOpts.expand(ds.X, ds.Head)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(taus) > 1 {
+		plotQuantiles(ds.X, ds.Y, fitQuantiles(ioutil.Discard, solverOpts, taus, basis, X, ds.Y, ds.W, head, false), head, basis, *outputFile)
+		fmt.Println(*outputFile)
+		return
+	}
+	result := fitWith(solverOpts, basis, X, ds.Y, ds.W)
+	if solverOpts.binomial() {
+		plotLogistic(ds.X, ds.Y, result.Coef, result.Intercept, head, basis, *solverOpts.seed, *outputFile)
+		fmt.Println(*outputFile)
+		return
+	}
+	summary, X, Y, W := summarizeFit(solverOpts, result, X, ds.Y, ds.W, head, *ciLevel)
+	plotFit(ds.X, ds.Y, result.Coef, result.Intercept, head, summary, basis, result.Weights, result.Inliers, *outputFile)
+	fmt.Println(*outputFile)
+	if *diagnosticsVisible {
+		fmt.Println(writeDiagnostics(X, Y, W, result.Coef, result.Intercept, summary, result.Inliers, *outputFile))
+	}
+}
+
+// runDescribe implements the describe subcommand, printing the first rows and
+// summary statistics of the selected columns without fitting anything
+func runDescribe(args []string) {
+	fs := flag.NewFlagSet("describe", flag.ExitOnError)
+	data := newDataFlags(fs)
+	fs.Parse(args)
+
+	ds := data.load()
+	fmt.Println("\n" + describeString(ds.X, ds.Y, ds.Head))
+	fmt.Print(describeColumns(ds.X, ds.Y, ds.Head))
+}
+
+// runCV implements the cv subcommand, estimating the out of sample error of a
+// solver by fitting it to some rows and testing it on the rest
+func runCV(args []string) {
+	fs := flag.NewFlagSet("cv", flag.ExitOnError)
+	data := newDataFlags(fs)
+	solverOpts := newSolverFlags(fs)
+	featureOpts := newFeatureFlags(fs)
+	cvOpts := newCVFlags(fs)
+	workers := fs.Int("workers", runtime.NumCPU(), "the number of folds evaluated concurrently")
+	format := fs.String("format", "text", "the format of the results on stdout: text, json for a single document, or csv for a row per fold")
+	fs.Parse(args)
+	if err := checkFormat(*format); err != nil {
+		log.Fatal(err)
+	}
+
+	data.unweighted("cv")
+	ds := data.load()
+	splits, err := cvOpts.splits(len(ds.X), *solverOpts.seed)
+	if err != nil {
+		log.Fatal(err)
+	}
+	solver, err := solverOpts.solver()
+	if err != nil {
+		log.Fatal(err)
+	}
+	res, err := crossValidate(ds.X, ds.Y, splits, solverFit(solver, featureOpts, ds.Head), *workers)
+	if err != nil {
+		log.Fatal(err)
+	}
+	res.Split = *cvOpts.mode
+	switch *format {
+	case "json":
+		err = res.writeJSON(os.Stdout)
+	case "csv":
+		err = res.writeCSV(os.Stdout)
+	default:
+		res.writeText(os.Stdout)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runTune implements the tune subcommand, cross-validating every candidate
+// setting of the tuned solver and feature flags, ranking them and refitting
+// the best to all of the rows
+func runTune(args []string) {
+	fs := flag.NewFlagSet("tune", flag.ExitOnError)
+	data := newDataFlags(fs)
+	solverOpts := newSolverFlags(fs)
+	newFeatureFlags(fs)
+	cvOpts := newCVFlags(fs)
+	var params paramFlags
+	fs.Var(&params, "param", "a tuned flag as name=v1,v2,... or name=distribution:low:high with a uniform, log-uniform or int-uniform distribution. May be repeated and overrides the spec")
+	specFile := fs.String("spec", "", "a json file with the search, trials and params of the search space")
+	search := fs.String("search", "grid", "grid to try every combination of the values, or random to sample -trials candidates")
+	trials := fs.Int("trials", 20, "the number of candidates sampled by random search")
+	metric := fs.String("metric", "rmse", "the mean fold metric candidates are ranked by: mae, rmse or r2")
+	workers := fs.Int("workers", runtime.NumCPU(), "the number of candidates evaluated concurrently")
+	saveFile := fs.String("save", "", "write the best model refitted to every row to this json file for use with the predict subcommand")
+	ciLevel := fs.Float64("ci", .95, "the confidence level of the coefficient confidence intervals of the best model")
+	format := fs.String("format", "text", "the format of the results on stdout: text, json for a single document, or csv for the ranked table. Human readable output goes to stderr with json and csv")
+	fs.Parse(args)
+	checkLevel(*ciLevel)
+	if err := checkFormat(*format); err != nil {
+		log.Fatal(err)
+	}
+	if *metric != "mae" && *metric != "rmse" && *metric != "r2" {
+		log.Fatalf("unknown metric %q, must be mae, rmse or r2", *metric)
+	}
+	if *metric == "r2" && *cvOpts.mode == "loo" {
+		log.Fatal("r2 is undefined on the single row folds of leave-one-out, rank by mae or rmse")
+	}
+	human := io.Writer(os.Stdout)
+	if *format != "text" {
+		human = os.Stderr
+	}
+
+	// the spec file is overridden by the flags given on the command line
+	spec := &searchSpec{Search: *search, Trials: *trials}
+	if *specFile != "" {
+		var err error
+		if spec, err = loadSearchSpec(*specFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if spec.Search == "" {
+		spec.Search = *search
+	}
+	if spec.Trials == 0 {
+		spec.Trials = *trials
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "search":
+			spec.Search = *search
+		case "trials":
+			spec.Trials = *trials
+		}
+	})
+	if spec.Params == nil {
+		spec.Params = make(map[string]paramSpec)
+	}
+	for _, p := range params {
+		name, ps, err := parseParam(p)
+		if err != nil {
+			log.Fatal(err)
+		}
+		spec.Params[name] = ps
+	}
+	rng := rand.New(rand.NewSource(*solverOpts.seed))
+	names, cands, err := spec.candidates(rng)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := checkTunable(names); err != nil {
+		log.Fatal(err)
+	}
+
+	data.unweighted("tune")
+	ds := data.load()
+	splits, err := cvOpts.splits(len(ds.X), *solverOpts.seed)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintf(human, "Evaluating %d candidates on %d splits\n\n", len(cands), len(splits))
+	results := tune(fs, ds.X, ds.Y, ds.Head, splits, names, cands, *metric, *workers)
+	if results[0].Error != "" {
+		log.Fatalf("every candidate failed, the first with: %s", results[0].Error)
+	}
+
+	// refit the best candidate to every row
+	best := make([]string, len(names))
+	for j, name := range names {
+		best[j] = results[0].Params[name]
+	}
+	bestSolver, bestFeatures, err := candidateFlags(fs, names, best)
+	if err != nil {
+		log.Fatal(err)
+	}
+	basis, X, head, err := bestFeatures.expand(ds.X, ds.Head)
+	if err != nil {
+		log.Fatal(err)
+	}
+	result := fitWith(bestSolver, basis, X, ds.Y, nil)
+	// the summary describes the rows the winner fitted as fit would, with
+	// the robust weights of a -robust winner and the inliers of ransac
+	summary, _, _, _ := summarizeFit(bestSolver, result, X, ds.Y, nil, head, *ciLevel)
+	out := &fitOutput{
+		Input:         *data.inputFile,
+		Solver:        bestSolver.label(),
+		Features:      head[:len(result.Coef)],
+		Response:      head[len(result.Coef)],
+		Coef:          result.Coef,
+		Intercept:     result.Intercept,
+		Condition:     result.Condition,
+		Summary:       summary,
+		Trace:         result.Trace,
+		RobustWeights: result.Weights,
+		RobustScale:   result.Scale,
+		Inliers:       result.Inliers,
+		Quality:       ds.Quality,
+		Plots:         []string{},
+	}
+	if out.Trace == nil {
+		out.Trace = []Iteration{}
+	}
+	if *saveFile != "" {
+		m := newModel(result.Coef, result.Intercept, head, out.Solver, summary, ds.Hash)
+		m.Basis = basis
+		if bestSolver.binomial() {
+			m.Family = "binomial"
+		}
+		if err := saveModel(m, *saveFile); err != nil {
+			log.Fatal(err)
+		}
+		out.Model = *saveFile
+	}
+
+	if *format == "text" {
+		writeTuneText(os.Stdout, names, results)
+	}
+	var settings []string
+	for j, name := range names {
+		settings = append(settings, "-"+name+"="+best[j])
+	}
+	line := "Regression Line"
+	if bestSolver.binomial() {
+		line += " (log odds)"
+	}
+	fmt.Fprintf(human, "\nBest: %s\n%s: %s\n\n", strings.Join(settings, " "), line, equationString(result.Coef, result.Intercept, head))
+	fmt.Fprintln(human, summary)
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(tuneOutput{Search: spec.Search, Metric: *metric, Results: results, Best: out})
+	case "csv":
+		err = writeTuneCSV(os.Stdout, names, results)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// paramFlags collects every -param flag given
+type paramFlags []string
+
+// String implements flag.Value
+func (p *paramFlags) String() string {
+	return strings.Join(*p, " ")
+}
+
+// Set implements flag.Value
+func (p *paramFlags) Set(s string) error {
+	*p = append(*p, s)
+	return nil
+}
+
+// runPath implements the path subcommand, fitting the penalized regression
+// across a log spaced grid of alphas and plotting how each coefficient shrinks
+func runPath(args []string) {
+	fs := flag.NewFlagSet("path", flag.ExitOnError)
+	data := newDataFlags(fs)
+	solverOpts := newSolverFlags(fs)
+	featureOpts := newFeatureFlags(fs)
+	nAlphas := fs.Int("n-alphas", 100, "the number of alphas along the path")
+	minRatio := fs.Float64("alpha-min-ratio", 1e-3, "the smallest alpha on the path as a fraction of the largest, the alpha at which every coefficient is zero")
+	outputFile := fs.String("o", "", "the name of the file the coefficient trajectories are plotted to, defaults to the input name with a _path.png suffix")
+	fs.Parse(args)
+	if *nAlphas < 1 {
+		log.Fatalf("the number of alphas must be positive, got %d", *nAlphas)
+	}
+	if *minRatio <= 0 || *minRatio >= 1 {
+		log.Fatalf("alpha min ratio %g must be between 0 and 1", *minRatio)
+	}
+	if *outputFile == "" {
+		*outputFile = strings.TrimSuffix(data.defaultOutput(""), ".png") + "_path.png"
+	}
+
+	data.unweighted("path")
+	ds := data.load()
+	_, X, head, err := featureOpts.expand(ds.X, ds.Head)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// -alpha is ignored, the grid starts from the alpha that zeroes every
+	// coefficient
+	solver, err := solverOpts.regularized()
+	if err != nil {
+		log.Fatal(err)
+	}
+	sc, err := newScaler(X, nil)
+	if err != nil {
+		fatalSolverError(err)
+	}
+	alphas := alphaGrid(maxAlpha(sc.transform(X), ds.Y, solver.L1Ratio), *minRatio, *nAlphas)
+	path, err := regularizationPath(solver, X, ds.Y, alphas)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	features := head[:len(head)-1]
+	fmt.Printf("%14s", "alpha")
+	for _, name := range features {
+		fmt.Printf("%14s", name)
+	}
+	fmt.Printf("%14s\n", "Intercept")
+	for _, pt := range path {
+		fmt.Printf("%14.6g", pt.Alpha)
+		for _, c := range pt.Coef {
+			fmt.Printf("%14.6g", c)
+		}
+		fmt.Printf("%14.6g\n", pt.Intercept)
+	}
+	plotPath(path, features, *outputFile)
+	fmt.Println(*outputFile)
+}
+
+// runPredict implements the predict subcommand, scoring each row of a csv with
+// a saved model and writing the rows back out with the prediction appended
+func runPredict(args []string) {
+	fs := flag.NewFlagSet("predict", flag.ExitOnError)
+	modelFile := fs.String("m", "model.json", "the model file written by fit with -save")
+	inputFile := fs.String("i", "", "input path to the csv to score, its header must name the model features")
+	outputFile := fs.String("o", "", "the csv the scored rows are written to, defaults to stdout")
+	interval := fs.String("interval", "none", "append the bounds of an interval around each prediction: none, confidence or prediction")
+	level := fs.Float64("level", .95, "the confidence level of the appended intervals")
+	fs.Parse(args)
+
+	if *inputFile == "" {
+		log.Fatal("predict needs an input csv given with -i")
+	}
+	if *interval != "none" && *interval != "confidence" && *interval != "prediction" {
+		log.Fatalf("unknown interval %q, must be none, confidence or prediction", *interval)
+	}
+	if *level <= 0 || *level >= 1 {
+		log.Fatalf("confidence level %g must be between 0 and 1", *level)
+	}
+	m, err := loadModel(*modelFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *interval != "none" && m.Summary.Covariance == nil {
+		log.Fatalf("%s: the model has no parameter covariance to compute intervals from", *modelFile)
+	}
+	if *interval == "prediction" && m.Family == "binomial" {
+		log.Fatalf("%s: a binomial model predicts probabilities, which only have confidence intervals", *modelFile)
+	}
+
+	in, err := os.Open(*inputFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer in.Close()
+	out := os.Stdout
+	if *outputFile != "" {
+		if out, err = os.Create(*outputFile); err != nil {
+			log.Fatal(err)
+		}
+		defer out.Close()
+	}
+	w := csv.NewWriter(out)
+	skipped, err := scoreCSV(m, csv.NewReader(in), w, *interval, *level)
+	if err != nil {
+		log.Fatalf("%s: %v", *inputFile, err)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Fatal(err)
+	}
+	if skipped > 0 {
+		log.Printf("%d rows had missing or non numeric features and were left without a prediction", skipped)
+	}
+}
+
+// summarizeFit returns the summary of the fit result of X, Y and the row
+// weights W, which may be nil, by solverOpts along with the rows, responses
+// and weights it describes. The row weights are multiplied by the final
+// weights of a robust fit, so its summary is that of weighted least squares
+// with them held fixed, and a ransac fit keeps only its inliers
+func summarizeFit(solverOpts *solverFlags, result *Result, X [][]float64, Y, W []float64, head []string, level float64) (FitSummary, [][]float64, []float64, []float64) {
+	if result.Weights != nil {
+		W = combineWeights(W, result.Weights)
+	}
+	if result.Inliers != nil {
+		X, Y, W = inlierRows(result.Inliers, X, Y, W)
+	}
+	if solverOpts.binomial() {
+		return newLogisticSummary(X, Y, W, result.Coef, result.Intercept, head, level), X, Y, W
+	}
+	return newFitSummary(X, Y, W, result.Coef, result.Intercept, head, level), X, Y, W
+}
+
+// fitWith fits the features X expanded by basis, which may be nil, with the
+// solver configured by the flags, weighting the rows by W unless it is nil,
+// exiting on failure
+func fitWith(solverOpts *solverFlags, basis *polyBasis, X [][]float64, Y, W []float64) *Result {
+	solver, err := solverOpts.solver()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var result *Result
+	if W == nil {
+		result, err = solver.Fit(X, Y)
+	} else if ws, ok := solver.(weightedSolver); ok {
+		result, err = ws.FitWeighted(X, Y, W)
+	} else {
+		log.Fatalf("the %s solver does not support row weights, remove -w", *solverOpts.name)
+	}
+	if err != nil {
+		fatalFitError(err, basis)
+	}
+	if result.Capped {
+		log.Printf("the %s solver used all %d epochs, with a constant learning rate its steps never settle below -e and the estimate is the last one, lower -lr to bring it closer to the minimum", *solverOpts.name, len(result.Trace))
+	}
+	return result
+}
+
+// fitQuantiles fits each quantile of taus in turn to the features X expanded by
+// basis, which may be nil, with the newton settings of the solver flags,
+// writing the regression line and goodness of fit of each to w
+func fitQuantiles(w io.Writer, solverOpts *solverFlags, taus []float64, basis *polyBasis, X [][]float64, Y, W []float64, head []string, iterationsVisible bool) []quantileFit {
+	fits := make([]quantileFit, len(taus))
+	for i, tau := range taus {
+		solver, err := solverOpts.quantileSolverAt(tau)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(w, "Starting Quantile %g Regression\n", tau)
+		result, err := solver.FitWeighted(X, Y, W)
+		if err != nil {
+			fatalFitError(err, basis)
+		}
+		if iterationsVisible {
+			writeTrace(w, result.Trace)
+		}
+		fits[i] = newQuantileFit(tau, X, Y, W, result.Coef, result.Intercept)
+		fmt.Fprintf(w, "\nRegression Line: %s\nCheck Loss: %.8f\nPseudo R1: %.8f\n\n",
+			equationString(result.Coef, result.Intercept, head), fits[i].CheckLoss, fits[i].PseudoR1)
+	}
+	return fits
+}
+
+// writeDiagnostics plots the residual diagnostics next to outputFile and
+// returns the name of the file written. When X holds only the inliers of a
+// ransac fit, inliers marks them among the rows read so that they are labelled
+// by their row of the data
+func writeDiagnostics(X [][]float64, Y, W []float64, coef []float64, b float64, summary FitSummary, inliers []bool, outputFile string) string {
+	if summary.Covariance == nil {
+		log.Fatal("diagnostics need the parameter covariance which could not be estimated")
+	}
+	fname := strings.TrimSuffix(outputFile, filepath.Ext(outputFile)) + "_diagnostics.png"
+	d := newDiagnostics(X, Y, W, coef, b, summary.Covariance, summary.ResidualSE*summary.ResidualSE)
+	if inliers != nil {
+		d.Rows = inlierRowNumbers(inliers)
+	}
+	plotDiagnostics(d, fname)
+	return fname
+}
+
+// describeColumns returns a table of the count, mean, standard deviation and
+// quartiles of each column
+func describeColumns(X [][]float64, Y []float64, head []string) string {
+	cols := make([][]float64, 0, len(head))
+	for j := 0; j < len(head)-1; j++ {
+		cols = append(cols, column(X, j))
+	}
+	cols = append(cols, Y)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%-12s %8s %12s %12s %12s %12s %12s %12s %12s\n", "", "count", "mean", "std", "min", "25%", "50%", "75%", "max")
+	for j, col := range cols {
+		var mean, variance float64
+		for _, v := range col {
+			mean += v / float64(len(col))
+		}
+		for _, v := range col {
+			variance += (v - mean) * (v - mean)
+		}
+		if len(col) > 1 {
+			variance /= float64(len(col) - 1)
+		}
+		sorted := append([]float64{}, col...)
+		sort.Float64s(sorted)
+		fmt.Fprintf(&sb, "%-12s %8d %12.4f %12.4f %12.4f %12.4f %12.4f %12.4f %12.4f\n",
+			head[j], len(col), mean, math.Sqrt(variance), sorted[0],
+			percentile(sorted, .25), percentile(sorted, .5), percentile(sorted, .75), sorted[len(sorted)-1])
+	}
+	return sb.String()
+}
+
+// percentile returns the q'th quantile of the sorted values interpolating
+// linearly between the closest ranks
+func percentile(sorted []float64, q float64) float64 {
+	pos := q * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	if lo+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lo] + (pos-float64(lo))*(sorted[lo+1]-sorted[lo])
+}
@@ -4,92 +4,387 @@ import (
 	"encoding/csv"
 	"fmt"
 	"io"
-	"log"
+	"math"
 	"strconv"
 	"strings"
 )
 
-// parseColumns parseColumns will get the columns of interest from the columns
-// string,  will return an error the column string is invalid
-func parseColumns(colstr string) (int, int, error) {
-	var xcol, ycol int
-	colErr := fmt.Errorf("invalid columns string format: %s must be in form %s where columns are different values", colstr, "0,1")
-	colSlice := strings.Split(colstr, ",")
-	if len(colSlice) != 2 {
-		fmt.Println("!=2")
-		return -1, -1, colErr
-	}
-	if i, err := strconv.Atoi(colSlice[0]); err == nil {
-		xcol = i
-	}
-	if i, err := strconv.Atoi(colSlice[1]); err == nil {
-		ycol = i
-	}
-	if xcol == ycol {
-		return -1, -1, colErr
-	}
-	return xcol, ycol, nil
+// parseColumns will get the columns of interest from the columns string, either
+// in the form "x,y" for a single predictor or "x1,x2,...:y" for any number of
+// predictors. Each column may be given as an index or as a header name, which
+// are resolved against the csv by resolveColumns. It will return an error if
+// the column string is invalid
+func parseColumns(colstr string) ([]string, string, error) {
+	colErr := fmt.Errorf("invalid columns string format: %s must be in form %s or %s where columns are different values", colstr, "0,1", "TV,Radio:Sales")
+	var xstr []string
+	var ystr string
+	if parts := strings.Split(colstr, ":"); len(parts) == 2 {
+		xstr = strings.Split(parts[0], ",")
+		ystr = parts[1]
+	} else if len(parts) == 1 {
+		colSlice := strings.Split(colstr, ",")
+		if len(colSlice) != 2 {
+			return nil, "", colErr
+		}
+		xstr = colSlice[:1]
+		ystr = colSlice[1]
+	} else {
+		return nil, "", colErr
+	}
+	ystr = strings.TrimSpace(ystr)
+	if ystr == "" {
+		return nil, "", colErr
+	}
+	for i := range xstr {
+		xstr[i] = strings.TrimSpace(xstr[i])
+		if xstr[i] == "" {
+			return nil, "", colErr
+		}
+	}
+	return xstr, ystr, nil
+}
+
+// resolveColumns maps each column given by parseColumns to its index in a csv
+// whose first record is first. Names are looked up in the header, which is nil
+// when the csv has none, and indices are checked against the record width
+func resolveColumns(xstr []string, ystr string, first, header []string) ([]int, int, error) {
+	ycol, err := resolveColumn(ystr, first, header)
+	if err != nil {
+		return nil, -1, err
+	}
+	seen := map[int]bool{ycol: true}
+	xcols := make([]int, len(xstr))
+	for i, s := range xstr {
+		if xcols[i], err = resolveColumn(s, first, header); err != nil {
+			return nil, -1, err
+		}
+		if seen[xcols[i]] {
+			return nil, -1, fmt.Errorf("column %q is used more than once", s)
+		}
+		seen[xcols[i]] = true
+	}
+	return xcols, ycol, nil
+}
+
+// resolveColumn maps a single column given by index or header name to its
+// index in a csv whose first record is first, as resolveColumns does
+func resolveColumn(s string, first, header []string) (int, error) {
+	if i, err := strconv.Atoi(s); err == nil {
+		if i < 0 || i >= len(first) {
+			return -1, fmt.Errorf("column index %d is out of range, the csv has %d columns (0 to %d)", i, len(first), len(first)-1)
+		}
+		return i, nil
+	}
+	if header == nil {
+		return -1, fmt.Errorf("column %q given by name but the csv has no header row", s)
+	}
+	for i, name := range header {
+		if strings.TrimSpace(name) == s {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("unknown column %q, available columns are: %s", s, strings.Join(header, ", "))
+}
+
+// isHeader reports whether a record is a header row, which is the case when
+// any of the fields at cols is neither numeric nor one of the missing value
+// tokens. A nil cols tests every field, otherwise columns that are not read,
+// such as an id or a date, cannot make the first row of data a header
+func isHeader(record []string, cols []int) bool {
+	if cols == nil {
+		cols = make([]int, len(record))
+		for i := range cols {
+			cols[i] = i
+		}
+	}
+	for _, i := range cols {
+		if i < 0 || i >= len(record) {
+			continue
+		}
+		field := record[i]
+		if isNA(field) {
+			continue
+		}
+		if _, err := strconv.ParseFloat(strings.TrimSpace(field), 64); err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// indexColumns returns the columns given as indices when every selector is
+// an index, or nil when any of them is a name that needs the header
+func indexColumns(selectors []string) []int {
+	cols := make([]int, len(selectors))
+	for i, s := range selectors {
+		col, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return nil
+		}
+		cols[i] = col
+	}
+	return cols
 }
 
 // describeString will return a string that has the up to the first five entries
-// of the values of interest along with header information for both columns
-func describeString(X, Y []float64, head string) string {
-	result := head + "\n"
+// of the values of interest along with header information for all columns
+func describeString(X [][]float64, Y []float64, head []string) string {
+	result := strings.Join(head, "\t") + "\n"
 	for i := 0; i < 5; i++ {
 		if i >= len(X) {
 			continue
 		}
-		result += fmt.Sprintf("%.3f\t%.3f\n", X[i], Y[i])
+		for _, x := range X[i] {
+			result += fmt.Sprintf("%.3f\t", x)
+		}
+		result += fmt.Sprintf("%.3f\n", Y[i])
 	}
 	return result
 }
 
-// readInValues takes in a csv reader and returns a two vectors for x and y
-// values respectively. The function will also skip over rows that have missing
-// data for row column values that are of interest. Also returns header
-// information of the csv file if their is any (headers cannot contain labels
-// that can be parsed intto numeric types)
-func readInValues(xcol, ycol int, reader *csv.Reader) ([]float64, []float64, string) {
-	var X []float64
-	var Y []float64
-	var head string
-	line := 1
+// rowReader reads the columns of interest from each row of a csv, applying
+// the missing value policy of each column and counting the outcome
+type rowReader struct {
+	reader *csv.Reader
+	// cols holds the index of each predictor followed by the response
+	cols     []int
+	head     []string
+	policies []missingPolicy
+	quality  DataQuality
+	// wcol is the index of the weight column, or -1 when rows are unweighted
+	wcol  int
+	wname string
+	// pending holds the first record when it is data rather than a header
+	pending []string
+	line    int
+}
+
+// newRowReader reads the first record of the csv to decide whether there is a
+// header and resolves the columns, given as parsed by parseColumns, along with
+// the missing value policy of each column in spec. wstr names the column of
+// row weights, or is empty when the rows are unweighted
+func newRowReader(xstr []string, ystr, wstr string, spec missingSpec, reader *csv.Reader) (*rowReader, error) {
+	// rows may be short, the missing columns are handled by their policy
+	reader.FieldsPerRecord = -1
+	first, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("csv is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	r := &rowReader{reader: reader, line: 1, wcol: -1}
+	selectors := append(append([]string{}, xstr...), ystr)
+	if wstr != "" {
+		selectors = append(selectors, wstr)
+	}
+	var header []string
+	if isHeader(first, indexColumns(selectors)) {
+		header = first
+	} else {
+		r.pending = first
+	}
+	xcols, ycol, err := resolveColumns(xstr, ystr, first, header)
+	if err != nil {
+		return nil, err
+	}
+	r.cols = append(append([]int{}, xcols...), ycol)
+	name := func(col int) string {
+		if header != nil {
+			return strings.TrimSpace(header[col])
+		}
+		return fmt.Sprintf("col%d", col)
+	}
+	r.head = make([]string, len(r.cols))
+	for i, col := range r.cols {
+		r.head[i] = name(col)
+	}
+	if wstr != "" {
+		if r.wcol, err = resolveColumn(wstr, first, header); err != nil {
+			return nil, fmt.Errorf("weights: %v", err)
+		}
+		for _, col := range r.cols {
+			if col == r.wcol {
+				return nil, fmt.Errorf("weight column %q is also a predictor or the response", wstr)
+			}
+		}
+		r.wname = name(r.wcol)
+	}
+	if r.policies, err = spec.policies(r.head, r.cols); err != nil {
+		return nil, err
+	}
+	r.quality.DroppedByReason = map[string]int{}
+	r.quality.Columns = make([]ColumnQuality, len(r.cols))
+	for i := range r.cols {
+		r.quality.Columns[i] = ColumnQuality{Name: r.head[i], Policy: r.policies[i].String()}
+	}
+	if r.wcol >= 0 {
+		// a row without a usable weight is an error whatever the policies
+		r.quality.Columns = append(r.quality.Columns, ColumnQuality{Name: r.wname, Policy: failMissing.String()})
+	}
+	return r, nil
+}
+
+// next returns the values of the next row that is kept, predictors followed by
+// the response, along with which of them are gaps still to be imputed and the
+// weight of the row, which is one when rows are unweighted. Rows with a value
+// to drop are skipped and io.EOF is returned after the last row. A weight that
+// is missing, not a number, negative or infinite is an error
+func (r *rowReader) next() ([]float64, []bool, float64, error) {
 	for {
 		// read lines of csv until reaching the end
-		record, err := reader.Read()
-		if err == io.EOF {
-			break
+		record := r.pending
+		r.pending = nil
+		if record == nil {
+			var err error
+			record, err = r.reader.Read()
+			if err == io.EOF {
+				return nil, nil, 0, io.EOF
+			}
+			if err != nil {
+				return nil, nil, 0, fmt.Errorf("reading line %d: %v", r.line+1, err)
+			}
+			r.line++
 		}
-		// handle header labels
-		if line == 1 {
-			line++
-			if _, err := strconv.Atoi(record[xcol]); err == nil {
-				head = ""
-				continue
+		r.quality.RowsRead++
+		weight, err := r.weight(record)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		values := make([]float64, len(r.cols))
+		gap := make([]bool, len(r.cols))
+		dropReason := ""
+		for i, col := range r.cols {
+			reason := ""
+			switch {
+			case col >= len(record):
+				reason = reasonShort
+				r.quality.Columns[i].Missing++
+			case isNA(record[col]):
+				reason = reasonMissing
+				r.quality.Columns[i].Missing++
+			default:
+				var err error
+				values[i], err = strconv.ParseFloat(strings.TrimSpace(record[col]), 64)
+				if err != nil {
+					reason = reasonInvalid
+					r.quality.Columns[i].Invalid++
+				}
 			}
-			head += record[xcol] + "\t"
-			if _, err := strconv.Atoi(record[ycol]); err == nil {
-				head = ""
+			if reason == "" {
 				continue
 			}
-			head += record[xcol]
-			continue
+			switch r.policies[i] {
+			case failMissing:
+				return nil, nil, 0, fmt.Errorf("line %d: column %s has a %s value", r.line, r.head[i], reason)
+			case dropMissing:
+				if dropReason == "" {
+					dropReason = reason
+				}
+			default:
+				gap[i] = true
+			}
 		}
-		// if there is a missing value in any row column that is being parsed
+		// if there is a value to drop in any row column that is being parsed
 		// the loop will skip the row entirely
-		XVal, err := strconv.ParseFloat(record[xcol], 64)
-		if err != nil {
-			log.Printf("Parseing line %d failed, enexpected type\n", line)
+		if dropReason != "" {
+			r.quality.DroppedByReason[dropReason]++
+			r.quality.RowsDropped++
 			continue
 		}
-		YVal, err := strconv.ParseFloat(record[ycol], 64)
+		r.quality.RowsUsed++
+		return values, gap, weight, nil
+	}
+}
+
+// weight parses the weight of a record, counting a missing or invalid weight
+// in the quality of the weight column before failing
+func (r *rowReader) weight(record []string) (float64, error) {
+	if r.wcol < 0 {
+		return 1, nil
+	}
+	q := &r.quality.Columns[len(r.cols)]
+	if r.wcol >= len(record) || isNA(record[r.wcol]) {
+		q.Missing++
+		return 0, fmt.Errorf("line %d: weight column %s has a missing value", r.line, r.wname)
+	}
+	w, err := strconv.ParseFloat(strings.TrimSpace(record[r.wcol]), 64)
+	switch {
+	case err != nil:
+		q.Invalid++
+		return 0, fmt.Errorf("line %d: weight column %s has an invalid value %q", r.line, r.wname, record[r.wcol])
+	case w < 0 || math.IsInf(w, 0):
+		q.Invalid++
+		return 0, fmt.Errorf("line %d: weight column %s has the weight %g, weights must be finite and not negative", r.line, r.wname, w)
+	}
+	return w, nil
+}
+
+// readInValues takes in a csv reader and returns the rows of the predictor
+// columns as a matrix along with a vector of y values, where the columns are
+// given as parsed by parseColumns. Values that are missing, not numeric or
+// beyond the end of a short row are handled by the policy of their column in
+// spec, and the outcome is counted in the returned data quality report. Also
+// returns the name of each column read, taken from the header of the csv file
+// if there is one (headers are detected by containing labels that cannot be
+// parsed into numeric types) and named by index otherwise. When wstr names a
+// weight column the weight of each row is returned as well, otherwise the
+// weights are nil
+func readInValues(xstr []string, ystr, wstr string, spec missingSpec, reader *csv.Reader) ([][]float64, []float64, []float64, []string, DataQuality, error) {
+	r, err := newRowReader(xstr, ystr, wstr, spec, reader)
+	if err != nil {
+		return nil, nil, nil, nil, DataQuality{}, err
+	}
+	// rows holds the values of every kept row and gaps marks the values of
+	// those rows that still have to be imputed
+	var rows [][]float64
+	var gaps [][]bool
+	var W []float64
+	for {
+		values, gap, weight, err := r.next()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			log.Printf("Parseing line %d failed, enexpected type\n", line)
+			return nil, nil, nil, nil, r.quality, err
+		}
+		rows = append(rows, values)
+		gaps = append(gaps, gap)
+		if r.wcol >= 0 {
+			W = append(W, weight)
+		}
+	}
+
+	// fill the gaps of each imputed column from its observed values in the
+	// rows that were kept
+	for i, policy := range r.policies {
+		if policy == dropMissing || policy == failMissing {
 			continue
 		}
-		X = append(X, XVal)
-		Y = append(Y, YVal)
-		line++
+		var observed []float64
+		for j := range rows {
+			if !gaps[j][i] {
+				observed = append(observed, rows[j][i])
+			}
+		}
+		if len(observed) == 0 && policy != imputeConstant {
+			return nil, nil, nil, nil, r.quality, fmt.Errorf("column %s has no observed values to impute from", r.head[i])
+		}
+		fill := imputeValue(policy, observed, spec.Constant)
+		for j := range rows {
+			if gaps[j][i] {
+				rows[j][i] = fill
+				r.quality.Columns[i].Imputed++
+			}
+		}
+	}
+
+	nx := len(r.cols) - 1
+	X := make([][]float64, len(rows))
+	Y := make([]float64, len(rows))
+	for j, values := range rows {
+		X[j] = values[:nx]
+		Y[j] = values[nx]
 	}
-	return X, Y, head
+	return X, Y, W, r.head, r.quality, nil
 }
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/csv"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseColumns(t *testing.T) {
+	tests := []struct {
+		colstr string
+		xstr   []string
+		ystr   string
+		ok     bool
+	}{
+		{"0,1", []string{"0"}, "1", true},
+		{"TV,Sales", []string{"TV"}, "Sales", true},
+		{"TV, 2 ,Newspaper:Sales", []string{"TV", "2", "Newspaper"}, "Sales", true},
+		{"0:3", []string{"0"}, "3", true},
+		{"0,1,2", nil, "", false},
+		{"0,:1", nil, "", false},
+		{"0:", nil, "", false},
+		{"0:1:2", nil, "", false},
+	}
+	for _, tt := range tests {
+		xstr, ystr, err := parseColumns(tt.colstr)
+		if (err == nil) != tt.ok {
+			t.Errorf("parseColumns(%q) error %v, want ok %v", tt.colstr, err, tt.ok)
+			continue
+		}
+		if tt.ok && (!reflect.DeepEqual(xstr, tt.xstr) || ystr != tt.ystr) {
+			t.Errorf("parseColumns(%q) = %q, %q, want %q, %q", tt.colstr, xstr, ystr, tt.xstr, tt.ystr)
+		}
+	}
+}
+
+func TestResolveColumns(t *testing.T) {
+	header := []string{"TV", " Radio ", "Newspaper", "Sales"}
+	first := header
+	tests := []struct {
+		name   string
+		xstr   []string
+		ystr   string
+		header []string
+		xcols  []int
+		ycol   int
+		err    string
+	}{
+		{"names", []string{"TV", "Radio"}, "Sales", header, []int{0, 1}, 3, ""},
+		{"indices", []string{"2", "0"}, "3", header, []int{2, 0}, 3, ""},
+		{"mixed", []string{"Newspaper", "1"}, "3", header, []int{2, 1}, 3, ""},
+		{"mixed without a header", []string{"0", "2"}, "1", nil, []int{0, 2}, 1, ""},
+		{"index out of range", []string{"TV"}, "4", header, nil, -1, "column index 4 is out of range, the csv has 4 columns (0 to 3)"},
+		{"negative index", []string{"-1"}, "Sales", header, nil, -1, "column index -1 is out of range"},
+		{"unknown name", []string{"Online"}, "Sales", header, nil, -1, `unknown column "Online", available columns are: TV,  Radio , Newspaper, Sales`},
+		{"name without a header", []string{"TV"}, "3", nil, nil, -1, `column "TV" given by name but the csv has no header row`},
+		{"repeated column", []string{"TV", "0"}, "Sales", header, nil, -1, `column "0" is used more than once`},
+		{"response as predictor", []string{"3"}, "Sales", header, nil, -1, `column "3" is used more than once`},
+	}
+	for _, tt := range tests {
+		xcols, ycol, err := resolveColumns(tt.xstr, tt.ystr, first, tt.header)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(xcols, tt.xcols) || ycol != tt.ycol {
+			t.Errorf("%s: resolved to %v, %d, want %v, %d", tt.name, xcols, ycol, tt.xcols, tt.ycol)
+		}
+	}
+}
+
+func TestReadInValuesHeaderlessText(t *testing.T) {
+	// the first column is a date that is not read, so the first row is data
+	const data = "2020-01-01,1,2,1\n2020-01-02,2,4,2\n2020-01-03,3,6,1\n"
+	spec, _ := parseMissing("drop", 0)
+	tests := []struct {
+		name string
+		xstr []string
+		ystr string
+		wstr string
+	}{
+		{"predictor and response", []string{"1"}, "2", ""},
+		{"weights", []string{"1"}, "2", "3"},
+	}
+	for _, tt := range tests {
+		X, Y, _, head, quality, err := readInValues(tt.xstr, tt.ystr, tt.wstr, spec, csv.NewReader(strings.NewReader(data)))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if quality.RowsRead != 3 || len(X) != 3 {
+			t.Errorf("%s: read %d rows and kept %d, want 3", tt.name, quality.RowsRead, len(X))
+		}
+		if want := []string{"col1", "col2"}; !reflect.DeepEqual(head, want) {
+			t.Errorf("%s: head %q, want %q", tt.name, head, want)
+		}
+		if want := []float64{2, 4, 6}; !reflect.DeepEqual(Y, want) {
+			t.Errorf("%s: responses %v, want %v", tt.name, Y, want)
+		}
+	}
+	// a name needs the header, which is found by any field that is not a number
+	const named = "date,x,y\n2020-01-01,1,2\n"
+	X, _, _, _, _, err := readInValues([]string{"x"}, "y", "", spec, csv.NewReader(strings.NewReader(named)))
+	if err != nil || len(X) != 1 {
+		t.Errorf("read %d rows of a csv with a header: %v", len(X), err)
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"strconv"
+	"sync"
+)
+
+// split is a single train and test partition of the rows by index
+type split struct {
+	Repeat int
+	Fold   int
+	Train  []int
+	Test   []int
+}
+
+// kFoldSplits deals repeats independent shuffles of n rows into k folds each,
+// every fold holding out one k-th of the rows. Leave-one-out is k equal to n
+func kFoldSplits(n, k, repeats int, rng *rand.Rand) []split {
+	var splits []split
+	for r := 0; r < repeats; r++ {
+		order := rng.Perm(n)
+		for fold := 0; fold < k; fold++ {
+			s := split{Repeat: r, Fold: fold}
+			for i, row := range order {
+				if i%k == fold {
+					s.Test = append(s.Test, row)
+				} else {
+					s.Train = append(s.Train, row)
+				}
+			}
+			splits = append(splits, s)
+		}
+	}
+	return splits
+}
+
+// holdoutSplit shuffles n rows and holds out the testSize fraction of them,
+// rounded to the nearest row
+func holdoutSplit(n int, testSize float64, rng *rand.Rand) (split, error) {
+	nTest := int(math.Round(testSize * float64(n)))
+	if nTest < 1 || n-nTest < 2 {
+		return split{}, fmt.Errorf("a test size of %g leaves %d of the %d rows for testing, at least one is needed to test and two to train", testSize, nTest, n)
+	}
+	order := rng.Perm(n)
+	return split{Train: order[nTest:], Test: order[:nTest]}, nil
+}
+
+// fitFunc trains a model on the training rows and returns its predictions for
+// the test rows
+type fitFunc func(trainX [][]float64, trainY []float64, testX [][]float64) ([]float64, error)
+
+// solverFit returns a fitFunc that fits the solver to the features the feature
+// flags expand the training rows into. The polynomial basis is fitted to the
+// training rows only so no information leaks from the test rows. A logistic
+// regression predicts probabilities, so its RMSE is the root of the brier
+// score
+func solverFit(solver Solver, features *featureFlags, head []string) fitFunc {
+	return func(trainX [][]float64, trainY []float64, testX [][]float64) ([]float64, error) {
+		basis, trainX, _, err := features.expand(trainX, head)
+		if err != nil {
+			return nil, err
+		}
+		if basis != nil {
+			testX = basis.expandAll(testX)
+		}
+		result, err := solver.Fit(trainX, trainY)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := solver.(logisticSolver); ok {
+			return predictProbabilities(testX, result.Coef, result.Intercept), nil
+		}
+		yPred := make([]float64, len(testX))
+		for i := range testX {
+			yPred[i] = predict(result.Coef, testX[i], result.Intercept)
+		}
+		return yPred, nil
+	}
+}
+
+// metrics are the errors of predictions on held out rows
+type metrics struct {
+	MAE  float64 `json:"mae"`
+	RMSE float64 `json:"rmse"`
+	// RSquared is one minus the residual sum of squares over the total sum
+	// of squares about the mean of the held out rows. It is NaN when a
+	// single row is held out
+	RSquared float64 `json:"r_squared"`
+}
+
+// newMetrics returns the errors of the predictions yPred of Y
+func newMetrics(Y, yPred []float64) metrics {
+	var mean, sse, sst float64
+	for _, y := range Y {
+		mean += y / float64(len(Y))
+	}
+	for i, y := range Y {
+		sse += (y - yPred[i]) * (y - yPred[i])
+		sst += (y - mean) * (y - mean)
+	}
+	r2 := math.NaN()
+	if len(Y) > 1 {
+		r2 = 1 - sse/sst
+	}
+	return metrics{MAE: calcMAE(Y, yPred), RMSE: math.Sqrt(sse / float64(len(Y))), RSquared: r2}
+}
+
+// MarshalJSON implements json.Marshaler, writing NaN or infinite metrics as
+// null
+func (m metrics) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		MAE      *float64 `json:"mae"`
+		RMSE     *float64 `json:"rmse"`
+		RSquared *float64 `json:"r_squared"`
+	}{nullable(m.MAE), nullable(m.RMSE), nullable(m.RSquared)})
+}
+
+// foldResult is the evaluation of a model on the test rows of one split
+type foldResult struct {
+	Repeat int `json:"repeat"`
+	Fold   int `json:"fold"`
+	Train  int `json:"train"`
+	Test   int `json:"test"`
+	// Metrics are the errors on the test rows
+	Metrics metrics `json:"metrics"`
+}
+
+// cvResult is the evaluation of a model across every split. Mean and SD
+// summarise the metrics of the folds, ignoring NaNs, while Pooled are the
+// metrics of every held out prediction taken together
+type cvResult struct {
+	Split  string       `json:"split"`
+	Folds  []foldResult `json:"folds"`
+	Mean   metrics      `json:"mean"`
+	SD     metrics      `json:"sd"`
+	Pooled metrics      `json:"pooled"`
+}
+
+// crossValidate evaluates fit on every split with a pool of workers, each
+// fitting and testing one split at a time. The folds are returned in the order
+// of the splits whatever order they finish in, so the result only depends on
+// the splits. The error of the earliest failing split is returned
+func crossValidate(X [][]float64, Y []float64, splits []split, fit fitFunc, workers int) (*cvResult, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	folds := make([]foldResult, len(splits))
+	preds := make([][]float64, len(splits))
+	errs := make([]error, len(splits))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				s := splits[i]
+				trainX, trainY := selectRows(X, Y, s.Train)
+				testX, testY := selectRows(X, Y, s.Test)
+				yPred, err := fit(trainX, trainY, testX)
+				if err != nil {
+					errs[i] = fmt.Errorf("repeat %d fold %d: %v", s.Repeat, s.Fold, err)
+					continue
+				}
+				preds[i] = yPred
+				folds[i] = foldResult{Repeat: s.Repeat, Fold: s.Fold, Train: len(s.Train), Test: len(s.Test), Metrics: newMetrics(testY, yPred)}
+			}
+		}()
+	}
+	for i := range splits {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	res := &cvResult{Folds: folds}
+	var allY, allPred []float64
+	for i, s := range splits {
+		for j, row := range s.Test {
+			allY, allPred = append(allY, Y[row]), append(allPred, preds[i][j])
+		}
+	}
+	res.Pooled = newMetrics(allY, allPred)
+	get := []func(m *metrics) *float64{
+		func(m *metrics) *float64 { return &m.MAE },
+		func(m *metrics) *float64 { return &m.RMSE },
+		func(m *metrics) *float64 { return &m.RSquared },
+	}
+	for _, field := range get {
+		var vals []float64
+		for i := range folds {
+			if v := *field(&folds[i].Metrics); !math.IsNaN(v) {
+				vals = append(vals, v)
+			}
+		}
+		*field(&res.Mean), *field(&res.SD) = meanSD(vals)
+	}
+	return res, nil
+}
+
+// selectRows returns the rows of X and Y at the given indices
+func selectRows(X [][]float64, Y []float64, rows []int) ([][]float64, []float64) {
+	subX, subY := make([][]float64, len(rows)), make([]float64, len(rows))
+	for i, row := range rows {
+		subX[i], subY[i] = X[row], Y[row]
+	}
+	return subX, subY
+}
+
+// meanSD returns the mean and sample standard deviation of vals, NaN when
+// there are too few of them
+func meanSD(vals []float64) (float64, float64) {
+	if len(vals) == 0 {
+		return math.NaN(), math.NaN()
+	}
+	var mean, ss float64
+	for _, v := range vals {
+		mean += v / float64(len(vals))
+	}
+	if len(vals) < 2 {
+		return mean, math.NaN()
+	}
+	for _, v := range vals {
+		ss += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(ss / float64(len(vals)-1))
+}
+
+// writeText writes a line per fold followed by the aggregated metrics
+func (r *cvResult) writeText(w io.Writer) {
+	format := func(v float64) string {
+		if math.IsNaN(v) {
+			return "-"
+		}
+		return fmt.Sprintf("%.8f", v)
+	}
+	for _, f := range r.Folds {
+		fmt.Fprintf(w, "Repeat: %d\tFold: %d\tTrain: %d\tTest: %d\tMAE: %s\tRMSE: %s\tR2: %s\n",
+			f.Repeat, f.Fold, f.Train, f.Test, format(f.Metrics.MAE), format(f.Metrics.RMSE), format(f.Metrics.RSquared))
+	}
+	fmt.Fprintf(w, "\n%-8s%16s%16s%16s\n", "", "MAE", "RMSE", "R2")
+	for _, row := range []struct {
+		name string
+		m    metrics
+	}{{"Mean", r.Mean}, {"SD", r.SD}, {"Pooled", r.Pooled}} {
+		fmt.Fprintf(w, "%-8s%16s%16s%16s\n", row.name, format(row.m.MAE), format(row.m.RMSE), format(row.m.RSquared))
+	}
+}
+
+// writeJSON writes the result to w as a single json document
+func (r *cvResult) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// writeCSV writes a row per fold to w, leaving NaN metrics blank
+func (r *cvResult) writeCSV(w io.Writer) error {
+	format := func(v float64) string {
+		if math.IsNaN(v) {
+			return ""
+		}
+		return formatFloat(v)
+	}
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"repeat", "fold", "train", "test", "mae", "rmse", "r_squared"})
+	for _, f := range r.Folds {
+		cw.Write([]string{strconv.Itoa(f.Repeat), strconv.Itoa(f.Fold), strconv.Itoa(f.Train), strconv.Itoa(f.Test),
+			format(f.Metrics.MAE), format(f.Metrics.RMSE), format(f.Metrics.RSquared)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
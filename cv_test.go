@@ -0,0 +1,125 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestKFoldSplits(t *testing.T) {
+	tests := []struct{ n, k, repeats int }{
+		{10, 5, 1},
+		{23, 5, 3},
+		{7, 7, 2},
+		{200, 10, 2},
+	}
+	for _, tt := range tests {
+		splits := kFoldSplits(tt.n, tt.k, tt.repeats, rand.New(rand.NewSource(1)))
+		if len(splits) != tt.k*tt.repeats {
+			t.Fatalf("n %d k %d: %d splits, want %d", tt.n, tt.k, len(splits), tt.k*tt.repeats)
+		}
+		for r := 0; r < tt.repeats; r++ {
+			// count how often each row is tested within the repeat
+			tested := make([]int, tt.n)
+			smallest, largest := tt.n, 0
+			for _, s := range splits[r*tt.k : (r+1)*tt.k] {
+				if s.Repeat != r {
+					t.Errorf("n %d k %d: split of repeat %d is labelled %d", tt.n, tt.k, r, s.Repeat)
+				}
+				if len(s.Train)+len(s.Test) != tt.n {
+					t.Errorf("n %d k %d: fold %d has %d train and %d test rows", tt.n, tt.k, s.Fold, len(s.Train), len(s.Test))
+				}
+				rows := append(append([]int{}, s.Train...), s.Test...)
+				sort.Ints(rows)
+				for i, row := range rows {
+					if row != i {
+						t.Fatalf("n %d k %d: fold %d does not partition the rows", tt.n, tt.k, s.Fold)
+					}
+				}
+				for _, row := range s.Test {
+					tested[row]++
+				}
+				if len(s.Test) < smallest {
+					smallest = len(s.Test)
+				}
+				if len(s.Test) > largest {
+					largest = len(s.Test)
+				}
+			}
+			for row, count := range tested {
+				if count != 1 {
+					t.Errorf("n %d k %d repeat %d: row %d is in %d test folds", tt.n, tt.k, r, row, count)
+				}
+			}
+			if largest-smallest > 1 {
+				t.Errorf("n %d k %d repeat %d: test folds range from %d to %d rows", tt.n, tt.k, r, smallest, largest)
+			}
+		}
+	}
+}
+
+func TestHoldoutSplit(t *testing.T) {
+	tests := []struct {
+		n        int
+		testSize float64
+		nTest    int
+	}{
+		{10, 0.2, 2},
+		{10, 0.25, 3},
+		{9, 0.25, 2},
+		{9, 0.3, 3},
+		{200, 0.3, 60},
+		{3, 0.3, 1},
+	}
+	for _, tt := range tests {
+		s, err := holdoutSplit(tt.n, tt.testSize, rand.New(rand.NewSource(1)))
+		if err != nil {
+			t.Errorf("n %d test size %g: %v", tt.n, tt.testSize, err)
+			continue
+		}
+		if len(s.Test) != tt.nTest || len(s.Train) != tt.n-tt.nTest {
+			t.Errorf("n %d test size %g: %d test and %d train rows, want %d and %d", tt.n, tt.testSize, len(s.Test), len(s.Train), tt.nTest, tt.n-tt.nTest)
+		}
+	}
+	for _, tt := range []struct {
+		n        int
+		testSize float64
+	}{{10, 0.04}, {10, 0.9}, {2, 0.5}} {
+		if _, err := holdoutSplit(tt.n, tt.testSize, rand.New(rand.NewSource(1))); err == nil {
+			t.Errorf("n %d test size %g: split accepted", tt.n, tt.testSize)
+		}
+	}
+}
+
+func TestCrossValidateWorkers(t *testing.T) {
+	X, Y := readTestCSV(t, "advertising.csv", []string{"TV", "Radio", "Newspaper"}, "Sales")
+	splits := kFoldSplits(len(X), 5, 3, rand.New(rand.NewSource(7)))
+	fit := func(trainX [][]float64, trainY []float64, testX [][]float64) ([]float64, error) {
+		result, err := qrSolver{}.Fit(trainX, trainY)
+		if err != nil {
+			return nil, err
+		}
+		yPred := make([]float64, len(testX))
+		for i := range testX {
+			yPred[i] = predict(result.Coef, testX[i], result.Intercept)
+		}
+		return yPred, nil
+	}
+	serial, err := crossValidate(X, Y, splits, fit, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parallel, err := crossValidate(X, Y, splits, fit, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(serial, parallel) {
+		t.Errorf("one worker gives\n%+v\nand eight\n%+v", serial, parallel)
+	}
+	for i, f := range serial.Folds {
+		if f.Repeat != splits[i].Repeat || f.Fold != splits[i].Fold {
+			t.Errorf("result %d is for repeat %d fold %d, want repeat %d fold %d", i, f.Repeat, f.Fold, splits[i].Repeat, splits[i].Fold)
+		}
+	}
+}
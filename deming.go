@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// demingSolver fits a line when both the predictor and the response are
+// measured with error, minimising the squared residuals along the direction
+// set by Ratio, the variance of the errors of the response over that of the
+// errors of the predictor. A ratio of one is total least squares, which
+// minimises the perpendicular distances to the line, while ordinary least
+// squares is the limit of an infinite ratio where only the response has error
+type demingSolver struct {
+	Ratio float64
+}
+
+// Fit implements Solver
+func (s demingSolver) Fit(X [][]float64, Y []float64) (*Result, error) {
+	return s.FitWeighted(X, Y, nil)
+}
+
+// FitWeighted implements weightedSolver, using the weighted means, variances
+// and covariance of the predictor and response
+func (s demingSolver) FitWeighted(X [][]float64, Y, W []float64) (*Result, error) {
+	if err := checkSinglePredictor("deming", X); err != nil {
+		return nil, err
+	}
+	if err := checkFinite(X, Y); err != nil {
+		return nil, err
+	}
+	if s.Ratio <= 0 || math.IsInf(s.Ratio, 0) || math.IsNaN(s.Ratio) {
+		return nil, fmt.Errorf("error variance ratio must be positive and finite, got %g", s.Ratio)
+	}
+	n := sumWeights(W, len(X))
+	var meanX, meanY float64
+	for i := range X {
+		meanX += weightAt(W, i) * X[i][0] / n
+		meanY += weightAt(W, i) * Y[i] / n
+	}
+	var sxx, syy, sxy float64
+	for i := range X {
+		dx, dy := X[i][0]-meanX, Y[i]-meanY
+		sxx += weightAt(W, i) * dx * dx / n
+		syy += weightAt(W, i) * dy * dy / n
+		sxy += weightAt(W, i) * dx * dy / n
+	}
+	if sxx == 0 {
+		return nil, &SolverError{Err: ErrRankDeficient, Iteration: -1, Detail: "the predictor is constant"}
+	}
+	slope, err := demingSlope(sxx, syy, sxy, s.Ratio)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Coef: []float64{slope}, Intercept: meanY - slope*meanX}, nil
+}
+
+// demingSlope returns the slope of the deming fit with error variance ratio
+// delta from the variances of x and y and their covariance. It is the root of
+// sxy*m^2 - (syy - delta*sxx)*m - delta*sxy with the sign of the covariance
+func demingSlope(sxx, syy, sxy, delta float64) (float64, error) {
+	d := syy - delta*sxx
+	if sxy == 0 {
+		// the line follows whichever variable spreads further
+		if d > 0 {
+			return 0, &SolverError{Err: ErrRankDeficient, Iteration: -1, Detail: "the predictor and response are uncorrelated and the response varies more, so the deming line is vertical"}
+		}
+		return 0, nil
+	}
+	return (d + math.Sqrt(d*d+4*delta*sxy*sxy)) / (2 * sxy), nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDemingSlope(t *testing.T) {
+	tests := []struct {
+		sxx, syy, sxy, delta float64
+		want                 float64
+	}{
+		// rows on y = 2x give the same line whatever the ratio
+		{1, 4, 2, 1, 2},
+		{1, 4, 2, 0.01, 2},
+		{1, 4, 2, 100, 2},
+		// total least squares follows the leading eigenvector of the
+		// covariance [[2 1] [1 1]]
+		{2, 1, 1, 1, (math.Sqrt(5) - 1) / 2},
+		{1, 1, -0.5, 1, -1},
+		// a large ratio tends to least squares of y on x, sxy/sxx, and a small
+		// one to least squares of x on y, syy/sxy
+		{2, 1, 1, 1e8, 0.5},
+		{2, 1, 1, 1e-8, 1},
+		// uncorrelated with x spreading further is a flat line
+		{3, 1, 0, 1, 0},
+	}
+	for _, tt := range tests {
+		got, err := demingSlope(tt.sxx, tt.syy, tt.sxy, tt.delta)
+		if err != nil {
+			t.Fatalf("demingSlope(%g, %g, %g, %g): %v", tt.sxx, tt.syy, tt.sxy, tt.delta, err)
+		}
+		if math.Abs(got-tt.want) > 1e-7 {
+			t.Errorf("demingSlope(%g, %g, %g, %g) = %.12g, want %.12g", tt.sxx, tt.syy, tt.sxy, tt.delta, got, tt.want)
+		}
+	}
+	if _, err := demingSlope(1, 3, 0, 1); err == nil {
+		t.Error("uncorrelated with y spreading further gave no error, want a vertical line error")
+	}
+}
+
+func TestTotalLeastSquaresIsSymmetric(t *testing.T) {
+	// with a ratio of one, swapping the predictor and the response inverts
+	// the slope, which least squares does not
+	X, Y := readTestCSV(t, "advertising.csv", []string{"TV"}, "Sales")
+	swapX := make([][]float64, len(Y))
+	for i := range Y {
+		swapX[i] = []float64{Y[i]}
+	}
+	xy, err := demingSolver{Ratio: 1}.Fit(X, Y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	yx, err := demingSolver{Ratio: 1}.Fit(swapX, column(X, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(xy.Coef[0]*yx.Coef[0]-1) > 1e-12 {
+		t.Errorf("slopes %.12g and %.12g are not reciprocal", xy.Coef[0], yx.Coef[0])
+	}
+	if math.Abs(yx.Intercept+xy.Intercept/xy.Coef[0]) > 1e-9 {
+		t.Errorf("swapped intercept %.12g, want %.12g", yx.Intercept, -xy.Intercept/xy.Coef[0])
+	}
+}
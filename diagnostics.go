@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+	"gonum.org/v1/plot/vg/vgimg"
+)
+
+// diagnostics holds the per observation quantities used to check the
+// assumptions of a least squares fit
+type diagnostics struct {
+	Fitted    []float64
+	Residuals []float64
+	// Standardized are the residuals divided by their estimated standard
+	// deviation, sigma*sqrt(1-h)
+	Standardized []float64
+	// Leverage is the diagonal of the hat matrix
+	Leverage []float64
+	// CooksDistance measures the influence of each observation on the fit.
+	// A row with leverage one is fitted exactly whatever its response, so it
+	// has no standardized residual or Cook's distance and both are NaN
+	CooksDistance []float64
+	// K is the number of parameters including the intercept
+	K int
+	// Rows holds the number of the row of the data each observation came
+	// from, which labels it in the plots
+	Rows []int
+}
+
+// rowNumber returns the number a row is reported by given its index in the
+// rows read, counting from one as a reader of the csv does
+func rowNumber(i int) int {
+	return i + 1
+}
+
+// newDiagnostics computes the diagnostics of the model with coefficients coef
+// and intercept b, where cov is the covariance of its parameters and sigma2
+// the residual variance. With weights W the leverage and standardized
+// residuals are those of the rows scaled by the square root of their weight
+func newDiagnostics(X [][]float64, Y, W []float64, coef []float64, b float64, cov [][]float64, sigma2 float64) diagnostics {
+	n := len(X)
+	d := diagnostics{
+		Fitted:        make([]float64, n),
+		Residuals:     make([]float64, n),
+		Standardized:  make([]float64, n),
+		Leverage:      make([]float64, n),
+		CooksDistance: make([]float64, n),
+		K:             len(coef) + 1,
+		Rows:          make([]int, n),
+	}
+	for i := range X {
+		d.Rows[i] = rowNumber(i)
+		d.Fitted[i] = predict(coef, X[i], b)
+		d.Residuals[i] = Y[i] - d.Fitted[i]
+		// the hat matrix diagonal is w z^T (X^T W X)^-1 z and cov is
+		// sigma^2 (X^T W X)^-1
+		z := append([]float64{1}, X[i]...)
+		var h float64
+		for j := range z {
+			for l := range z {
+				h += z[j] * cov[j][l] * z[l]
+			}
+		}
+		w := weightAt(W, i)
+		h *= w / sigma2
+		d.Leverage[i] = h
+		if h >= 1-1e-12 {
+			d.Standardized[i], d.CooksDistance[i] = math.NaN(), math.NaN()
+			continue
+		}
+		d.Standardized[i] = math.Sqrt(w) * d.Residuals[i] / math.Sqrt(sigma2*(1-h))
+		d.CooksDistance[i] = d.Standardized[i] * d.Standardized[i] * h / (float64(d.K) * (1 - h))
+	}
+	return d
+}
+
+// influentialCooks is the Cook's distance above which a row is labelled as
+// influential in the leverage panel
+const influentialCooks = 0.5
+
+// normalQuantile returns the value z where P(Z <= z) = p for a standard normal
+func normalQuantile(p float64) float64 {
+	return math.Sqrt2 * math.Erfinv(2*p-1)
+}
+
+// leveragePoints returns the standardized residual against the leverage of
+// every row, with the rows whose Cook's distance is above influentialCooks
+// labelled with their number in Rows. Rows with leverage one have no
+// standardized residual and rows of weight zero have leverage zero, where the
+// contours of Cook's distance are infinite, so both are left out
+func leveragePoints(d diagnostics) (plotter.XYs, plotter.XYLabels) {
+	var pts plotter.XYs
+	var influential plotter.XYLabels
+	for i, h := range d.Leverage {
+		if math.IsNaN(d.Standardized[i]) || h <= 0 {
+			continue
+		}
+		pt := plotter.XY{X: h, Y: d.Standardized[i]}
+		pts = append(pts, pt)
+		if d.CooksDistance[i] > influentialCooks {
+			influential.XYs = append(influential.XYs, pt)
+			influential.Labels = append(influential.Labels, fmt.Sprint(d.Rows[i]))
+		}
+	}
+	return pts, influential
+}
+
+// plotDiagnostics saves a two by two figure to fname with residuals against
+// fitted values, a normal Q-Q plot of the standardized residuals, the
+// scale-location plot and the points of leveragePoints with contours of
+// Cook's distance. Rows with leverage one
+// are left out of the panels of standardized residuals
+func plotDiagnostics(d diagnostics, fname string) {
+	var residPts, qqPts, scalePts plotter.XYs
+	var sorted []float64
+	for i := range d.Fitted {
+		residPts = append(residPts, plotter.XY{X: d.Fitted[i], Y: d.Residuals[i]})
+		if math.IsNaN(d.Standardized[i]) {
+			continue
+		}
+		scalePts = append(scalePts, plotter.XY{X: d.Fitted[i], Y: math.Sqrt(math.Abs(d.Standardized[i]))})
+		sorted = append(sorted, d.Standardized[i])
+	}
+	leveragePts, influential := leveragePoints(d)
+	if skipped := len(d.Fitted) - len(sorted); skipped > 0 {
+		log.Printf("%d rows with leverage one are fitted exactly and left out of the standardized residual panels", skipped)
+	}
+	if len(sorted) == 0 {
+		log.Fatal("every row has leverage one, there are no standardized residuals to plot")
+	}
+	// pair the sorted standardized residuals with normal quantiles at blom's
+	// plotting positions
+	sort.Float64s(sorted)
+	n := len(sorted)
+	for i, r := range sorted {
+		p := (float64(i+1) - 0.375) / (float64(n) + 0.25)
+		qqPts = append(qqPts, plotter.XY{X: normalQuantile(p), Y: r})
+	}
+
+	resid := diagnosticPanel("Residuals vs Fitted", "Fitted values", "Residuals", residPts)
+	resid.Add(referenceLine(func(float64) float64 { return 0 }))
+	qq := diagnosticPanel("Normal Q-Q", "Theoretical quantiles", "Standardized residuals", qqPts)
+	qq.Add(referenceLine(func(x float64) float64 { return x }))
+	scale := diagnosticPanel("Scale-Location", "Fitted values", "sqrt(|Standardized residuals|)", scalePts)
+	leverage := diagnosticPanel("Residuals vs Leverage", "Leverage", "Standardized residuals", leveragePts)
+	leverage.Add(referenceLine(func(float64) float64 { return 0 }))
+	// cook's distance D is constant along r = +-sqrt(D k (1-h) / h)
+	hmin, hmax := math.Inf(1), math.Inf(-1)
+	for _, pt := range leveragePts {
+		hmin, hmax = math.Min(hmin, pt.X), math.Max(hmax, pt.X)
+	}
+	contours := []float64{0.5, 1}
+	if len(leveragePts) == 0 {
+		contours = nil
+	}
+	for _, D := range contours {
+		for _, sign := range []float64{-1, 1} {
+			D, sign := D, sign
+			f := plotter.NewFunction(func(h float64) float64 {
+				return sign * math.Sqrt(D*float64(d.K)*(1-h)/h)
+			})
+			f.XMin, f.XMax = hmin, hmax
+			f.Dashes = []vg.Length{vg.Points(2), vg.Points(2)}
+			f.Color = plotter.DefaultLineStyle.Color
+			leverage.Add(f)
+			if sign > 0 {
+				leverage.Legend.Add(fmt.Sprintf("Cook's distance %g", D), f)
+			}
+		}
+	}
+	if len(influential.XYs) > 0 {
+		labels, err := plotter.NewLabels(influential)
+		if err != nil {
+			log.Fatal(err)
+		}
+		labels.XOffset, labels.YOffset = -vg.Points(4), vg.Points(3)
+		leverage.Add(labels)
+	}
+	leverage.Y.Min = math.Min(leverage.Y.Min, -3)
+	leverage.Y.Max = math.Max(leverage.Y.Max, 3)
+	leverage.Legend.Top = true
+
+	// lay the panels out on a single canvas
+	const rows, cols = 2, 2
+	plots := [][]*plot.Plot{{resid, qq}, {scale, leverage}}
+	img := vgimg.New(8*vg.Inch, 8*vg.Inch)
+	dc := draw.New(img)
+	tiles := draw.Tiles{
+		Rows: rows, Cols: cols,
+		PadX: vg.Millimeter, PadY: vg.Millimeter,
+		PadTop: vg.Points(2), PadBottom: vg.Points(2),
+		PadLeft: vg.Points(2), PadRight: vg.Points(2),
+	}
+	canvases := plot.Align(plots, tiles, dc)
+	for j := 0; j < rows; j++ {
+		for i := 0; i < cols; i++ {
+			plots[j][i].Draw(canvases[j][i])
+		}
+	}
+	f, err := os.Create(fname)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := (vgimg.PngCanvas{Canvas: img}).WriteTo(f); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// diagnosticPanel returns a plot titled title with a scatter of pts
+func diagnosticPanel(title, xLabel, yLabel string, pts plotter.XYs) *plot.Plot {
+	p, err := plot.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	p.Title.Text = title
+	p.X.Label.Text = xLabel
+	p.Y.Label.Text = yLabel
+	p.Add(plotter.NewGrid())
+	s, err := plotter.NewScatter(pts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	s.GlyphStyle.Radius = vg.Points(2)
+	p.Add(s)
+	return p
+}
+
+// referenceLine returns a dashed line tracing f across the plot
+func referenceLine(f func(float64) float64) *plotter.Function {
+	l := plotter.NewFunction(f)
+	l.Dashes = []vg.Length{vg.Points(5), vg.Points(5)}
+	return l
+}
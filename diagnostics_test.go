@@ -0,0 +1,88 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// diagnosticsFor fits X and Y with weights W by qr and returns their
+// diagnostics
+func diagnosticsFor(t *testing.T, X [][]float64, Y, W []float64) diagnostics {
+	t.Helper()
+	coef, b, _, err := qrRegression(X, Y, W)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head := make([]string, len(X[0])+1)
+	summary := newFitSummary(X, Y, W, coef, b, head, .95)
+	return newDiagnostics(X, Y, W, coef, b, summary.Covariance, summary.ResidualSE*summary.ResidualSE)
+}
+
+func TestDiagnosticsCooksDistance(t *testing.T) {
+	// x has mean 4 and Sxx 50, the fit is y = 0.84 + 0.74x with s^2 = 1.14,
+	// so the last row has h = 1/5 + 36/50 = 0.92 and residual -0.24, and
+	// D = e^2 / (k s^2) h / (1-h)^2 = 0.0576 / 2.28 * 0.92 / 0.0064 = 69/19
+	X := [][]float64{{1}, {2}, {3}, {4}, {10}}
+	Y := []float64{1, 3, 2, 5, 8}
+	d := diagnosticsFor(t, X, Y, nil)
+	if d.K != 2 {
+		t.Errorf("K = %d, want 2", d.K)
+	}
+	for i, want := range []float64{.38, .28, .22, .2, .92} {
+		if math.Abs(d.Leverage[i]-want) > 1e-12 {
+			t.Errorf("row %d has leverage %g, want %g", i, d.Leverage[i], want)
+		}
+	}
+	if got, want := d.CooksDistance[4], 69.0/19; math.Abs(got-want) > 1e-12 {
+		t.Errorf("Cook's distance of the last row is %.15g, want %.15g", got, want)
+	}
+	if got, want := d.Standardized[4], -.24/math.Sqrt(1.14*.08); math.Abs(got-want) > 1e-12 {
+		t.Errorf("standardized residual of the last row is %g, want %g", got, want)
+	}
+	pts, influential := leveragePoints(d)
+	if len(pts) != len(X) || len(influential.Labels) != 1 || influential.Labels[0] != "5" {
+		t.Errorf("%d leverage points with labels %q, want 5 with the label 5", len(pts), influential.Labels)
+	}
+}
+
+func TestDiagnosticsLeverageSum(t *testing.T) {
+	X, Y := readTestCSV(t, "advertising.csv", []string{"TV", "Radio", "Newspaper"}, "Sales")
+	W := make([]float64, len(X))
+	for i := range W {
+		W[i] = float64(i % 3)
+	}
+	for _, tt := range []struct {
+		name string
+		W    []float64
+	}{{"unweighted", nil}, {"weighted", W}} {
+		d := diagnosticsFor(t, X, Y, tt.W)
+		// the trace of the hat matrix is the number of parameters
+		var sum float64
+		for _, h := range d.Leverage {
+			sum += h
+		}
+		if math.Abs(sum-float64(d.K)) > 1e-9 {
+			t.Errorf("%s: leverages sum to %.12g, want %d", tt.name, sum, d.K)
+		}
+		// rows of weight zero have no leverage and are left out of the
+		// leverage panel, where the contours of Cook's distance are infinite
+		pts, _ := leveragePoints(d)
+		zero := 0
+		for i := range X {
+			if weightAt(tt.W, i) == 0 {
+				zero++
+				if d.Leverage[i] != 0 {
+					t.Errorf("%s: row %d of weight zero has leverage %g", tt.name, i, d.Leverage[i])
+				}
+			}
+		}
+		if len(pts) != len(X)-zero {
+			t.Errorf("%s: %d leverage points, want %d", tt.name, len(pts), len(X)-zero)
+		}
+		for _, pt := range pts {
+			if pt.X <= 0 {
+				t.Errorf("%s: leverage panel has a point at leverage %g", tt.name, pt.X)
+			}
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"math"
+)
+
+// regIncBeta returns the regularized incomplete beta function I_x(a, b) using
+// the continued fraction expansion from Numerical Recipes
+func regIncBeta(a, b, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	lgab, _ := math.Lgamma(a + b)
+	lga, _ := math.Lgamma(a)
+	lgb, _ := math.Lgamma(b)
+	front := math.Exp(lgab - lga - lgb + a*math.Log(x) + b*math.Log(1-x))
+	// the continued fraction converges quickly only on this side of the mean,
+	// use the symmetry I_x(a, b) = 1 - I_1-x(b, a) on the other
+	if x < (a+1)/(a+b+2) {
+		return front * betaContinuedFraction(a, b, x) / a
+	}
+	return 1 - front*betaContinuedFraction(b, a, 1-x)/b
+}
+
+// betaContinuedFraction evaluates the continued fraction for the incomplete
+// beta function with the modified Lentz method
+func betaContinuedFraction(a, b, x float64) float64 {
+	const tiny = 1e-300
+	qab, qap, qam := a+b, a+1, a-1
+	c := 1.0
+	d := 1 - qab*x/qap
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1; m <= 300; m++ {
+		fm := float64(m)
+		m2 := 2 * fm
+		// even step of the recurrence
+		aa := fm * (b - fm) * x / ((qam + m2) * (a + m2))
+		d = 1 + aa*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+		// odd step of the recurrence
+		aa = -(a + fm) * (qab + fm) * x / ((a + m2) * (qap + m2))
+		d = 1 + aa*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < 1e-15 {
+			break
+		}
+	}
+	return h
+}
+
+// studentTCDF returns P(T <= t) for a student t distribution with df degrees
+// of freedom
+func studentTCDF(t, df float64) float64 {
+	tail := 0.5 * regIncBeta(df/2, 0.5, df/(df+t*t))
+	if math.Abs(t) < 1 {
+		// df/(df+t^2) rounds to one for t near zero, so the tail is taken
+		// from the share of the distribution within t of zero instead
+		tail = 0.5 - 0.5*regIncBeta(0.5, df/2, t*t/(df+t*t))
+	}
+	if t > 0 {
+		return 1 - tail
+	}
+	return tail
+}
+
+// studentTQuantile returns the value t where P(T <= t) = p for a student t
+// distribution with df degrees of freedom
+func studentTQuantile(p, df float64) float64 {
+	return quantile(func(t float64) float64 { return studentTCDF(t, df) }, p, 0, 1)
+}
+
+// quantile inverts the continuous increasing cdf at p by expanding a bracket
+// around start, in steps growing from width, and then bisecting it
+func quantile(cdf func(float64) float64, p, start, width float64) float64 {
+	if p <= 0 {
+		return math.Inf(-1)
+	}
+	if p >= 1 {
+		return math.Inf(1)
+	}
+	lo, hi := start-width, start+width
+	for cdf(lo) > p {
+		lo -= hi - lo
+	}
+	for cdf(hi) < p {
+		hi += hi - lo
+	}
+	for i := 0; i < 200 && hi-lo > 1e-12*math.Max(1, math.Abs(lo)); i++ {
+		mid := (lo + hi) / 2
+		if cdf(mid) < p {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2
+}
+
+// fSurvival returns P(F > f) for an F distribution with d1 and d2 degrees of
+// freedom without the cancellation of computing 1 - fCDF for large f
+func fSurvival(f, d1, d2 float64) float64 {
+	if f <= 0 {
+		return 1
+	}
+	return regIncBeta(d2/2, d1/2, d2/(d1*f+d2))
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRegIncBeta(t *testing.T) {
+	tests := []struct {
+		a, b, x float64
+		want    float64
+	}{
+		// I_x(a, 1) = x^a and I_x(1, b) = 1 - (1-x)^b
+		{2.5, 1, 0.3, math.Pow(0.3, 2.5)},
+		{1, 4, 0.2, 1 - math.Pow(0.8, 4)},
+		{1, 4, 0.9, 1 - math.Pow(0.1, 4)},
+		// the distribution of a symmetric beta is centred on a half
+		{7, 7, 0.5, 0.5},
+		{0.5, 0.5, 0.5, 0.5},
+		{3, 2, 0, 0},
+		{3, 2, 1, 1},
+	}
+	for _, tt := range tests {
+		if got := regIncBeta(tt.a, tt.b, tt.x); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("regIncBeta(%g, %g, %g) = %.15g, want %.15g", tt.a, tt.b, tt.x, got, tt.want)
+		}
+	}
+}
+
+func TestStudentTCDF(t *testing.T) {
+	tests := []struct {
+		t, df float64
+		want  float64
+	}{
+		// one degree of freedom is the cauchy distribution and two has the
+		// closed form 1/2 + t/(2 sqrt(2+t^2))
+		{1, 1, 0.75},
+		{-3, 1, 0.5 + math.Atan(-3)/math.Pi},
+		{0.7, 2, 0.5 + 0.7/(2*math.Sqrt(2+0.7*0.7))},
+		{-5, 2, 0.5 - 5/(2*math.Sqrt(2+25))},
+		{1e-9, 2, 0.5 + 1e-9/(2*math.Sqrt(2+1e-18))},
+		{0, 17, 0.5},
+	}
+	for _, tt := range tests {
+		if got := studentTCDF(tt.t, tt.df); math.Abs(got-tt.want) > 1e-14 {
+			t.Errorf("studentTCDF(%g, %g) = %.15g, want %.15g", tt.t, tt.df, got, tt.want)
+		}
+	}
+}
+
+func TestStudentTQuantile(t *testing.T) {
+	tests := []struct {
+		p, df float64
+		want  float64
+	}{
+		{0.975, 1, 12.706204736174698},
+		{0.975, 2, 4.302652729749464},
+		{0.975, 5, 2.570581835636314},
+		{0.975, 10, 2.2281388519862744},
+		{0.975, 30, 2.0422724563012373},
+		{0.95, 20, 1.7247182429207857},
+		{0.995, 1, 63.65674116287399},
+		{0.025, 10, -2.2281388519862744},
+		{0.5, 4, 0},
+	}
+	for _, tt := range tests {
+		if got := studentTQuantile(tt.p, tt.df); math.Abs(got-tt.want) > 1e-8*math.Max(1, math.Abs(tt.want)) {
+			t.Errorf("studentTQuantile(%g, %g) = %.12g, want %.12g", tt.p, tt.df, got, tt.want)
+		}
+	}
+}
+
+func TestFSurvival(t *testing.T) {
+	tests := []struct {
+		f, d1, d2 float64
+		want      float64
+	}{
+		// with two numerator degrees of freedom P(F > f) = (1 + 2f/d2)^(-d2/2)
+		{3, 2, 10, math.Pow(1+2*3.0/10, -5)},
+		{0.5, 2, 7, math.Pow(1+2*0.5/7, -3.5)},
+		// upper 5% and 1% critical values from the F tables
+		{4.964602743730711, 1, 10, 0.05},
+		{3.492828476735632, 2, 20, 0.05},
+		{3.325834530413011, 5, 10, 0.05},
+		{161.44763879758953, 1, 1, 0.05},
+		{10.044289273396597, 1, 10, 0.01},
+		{0, 3, 12, 1},
+	}
+	for _, tt := range tests {
+		if got := fSurvival(tt.f, tt.d1, tt.d2); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("fSurvival(%g, %g, %g) = %.12g, want %.12g", tt.f, tt.d1, tt.d2, got, tt.want)
+		}
+	}
+}
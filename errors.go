@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Errors reported by the solvers. They are wrapped in a SolverError so the
+// cause can be compared against these values
+var (
+	ErrSingularHessian = errors.New("hessian is singular or ill-conditioned")
+	ErrRankDeficient   = errors.New("design matrix is rank deficient")
+	ErrNotConverged    = errors.New("solver did not converge")
+	ErrDiverged        = errors.New("solver diverged")
+	ErrNonFinite       = errors.New("non-finite value encountered")
+	ErrLineSearch      = errors.New("line search failed")
+	ErrNoConsensus     = errors.New("no consensus set found")
+)
+
+// SolverError describes why and where a solver stopped. Err is one of the
+// sentinel errors above and Iteration is negative when the failure happened
+// before iterating
+type SolverError struct {
+	Err       error
+	Iteration int
+	Detail    string
+}
+
+// Error implements error
+func (e *SolverError) Error() string {
+	msg := e.Err.Error()
+	if e.Iteration >= 0 {
+		msg += fmt.Sprintf(" at iteration %d", e.Iteration)
+	}
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	return msg
+}
+
+// Hint returns advice on how to get past the error from the command line
+func (e *SolverError) Hint() string {
+	switch e.Err {
+	case ErrSingularHessian, ErrRankDeficient:
+		return "check for constant columns or predictors that are linear combinations of each other"
+	case ErrNotConverged:
+		return "increase -max-iter or -epochs, or loosen -e"
+	case ErrDiverged:
+		return "lower the learning rate with -lr or try the newton or qr solver"
+	case ErrLineSearch:
+		return "try a different -line-search or the qr solver"
+	case ErrNoConsensus:
+		return "raise -ransac-threshold or -max-trials"
+	case ErrNonFinite:
+		return "check the input for NaN or Inf values and rescale very large columns"
+	}
+	return ""
+}
+
+// checkFinite returns an error if any value of X or Y is NaN or infinite
+func checkFinite(X [][]float64, Y []float64) error {
+	for i := range X {
+		for j, x := range X[i] {
+			if math.IsNaN(x) || math.IsInf(x, 0) {
+				return &SolverError{Err: ErrNonFinite, Iteration: -1, Detail: fmt.Sprintf("row %d predictor %d is %v", i, j+1, x)}
+			}
+		}
+		if math.IsNaN(Y[i]) || math.IsInf(Y[i], 0) {
+			return &SolverError{Err: ErrNonFinite, Iteration: -1, Detail: fmt.Sprintf("row %d response is %v", i, Y[i])}
+		}
+	}
+	return nil
+}
+
+// isFinite reports whether every value of v is neither NaN nor infinite
+func isFinite(v []float64) bool {
+	for _, x := range v {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// optimizer is the rule used to turn a gradient into a parameter update
+type optimizer int
+
+const (
+	// plainUpdate steps against the gradient scaled by the learning rate
+	plainUpdate optimizer = iota
+	// momentumUpdate accumulates an exponentially decaying velocity of past
+	// gradients and steps along it
+	momentumUpdate
+	// adamUpdate scales each parameter's step by running estimates of the first
+	// and second moments of its gradient
+	adamUpdate
+)
+
+// divergenceWindow is the number of consecutive epochs with a rising loss after
+// which plain batch gradient descent gives up
+const divergenceWindow = 10
+
+// divergenceFactor is the multiple of its lowest loss, once also above the
+// loss it started from, at which a stochastic gradient solver gives up. Its
+// loss is too noisy to rise steadily, so only runaway growth counts
+const divergenceFactor = 100
+
+// defaultMiniBatch is the batch size of the minibatch solver when -batch is
+// left at zero
+const defaultMiniBatch = 32
+
+// gradientSolver fits the model with first order gradient descent on the mean
+// squared error. A BatchSize of zero uses every row for each step (batch
+// gradient descent), one gives stochastic gradient descent and anything larger
+// mini-batch gradient descent, with the rows shuffled every epoch by a
+// generator seeded with Seed. Columns are standardized internally so a single
+// learning rate suits all of them, and the parameters are transformed back to
+// the original scale. Fitting stops once an epoch moves the parameters less
+// than Epsilon times the learning rate. Batch gradient descent fails with
+// ErrNotConverged after MaxEpochs passes, while the stochastic variants never
+// settle with a constant learning rate and return their estimate at that point
+// marked as Capped. Plain batch gradient descent diverges once its loss rises
+// for divergenceWindow epochs in a row, and the stochastic variants, momentum
+// and adam, whose loss need not fall every epoch, once it passes
+// divergenceFactor times its lowest
+type gradientSolver struct {
+	LearningRate float64
+	Epsilon      float64
+	MaxEpochs    int
+	BatchSize    int
+	Seed         int64
+	Optimizer    optimizer
+}
+
+// Fit implements Solver
+func (s gradientSolver) Fit(X [][]float64, Y []float64) (*Result, error) {
+	n, p := len(X), len(X[0])
+	if s.LearningRate <= 0 {
+		return nil, fmt.Errorf("learning rate must be positive, got %g", s.LearningRate)
+	}
+	if s.MaxEpochs < 1 {
+		return nil, fmt.Errorf("epochs must be at least one, got %d", s.MaxEpochs)
+	}
+	if err := checkFinite(X, Y); err != nil {
+		return nil, err
+	}
+	// standardize each column to zero mean and unit variance
+	sc, err := newScaler(X, nil)
+	if err != nil {
+		return nil, err
+	}
+	Z := designMatrix(sc.transform(X))
+	unscale := sc.unscale
+
+	batchSize := s.BatchSize
+	if batchSize <= 0 || batchSize > n {
+		batchSize = n
+	}
+	rng := rand.New(rand.NewSource(s.Seed))
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	k := p + 1
+	theta := make([]float64, k)
+	prev := make([]float64, k)
+	grad := make([]float64, k)
+	// optimizer state, velocity for momentum and the moment estimates for adam
+	velocity := make([]float64, k)
+	m1, m2 := make([]float64, k), make([]float64, k)
+	const beta, beta1, beta2, adamEps = 0.9, 0.9, 0.999, 1e-8
+	updates := 0
+
+	// only plain batch gradient descent lowers the loss every epoch once its
+	// learning rate is small enough, sampled batches and the inertia of
+	// momentum and adam make the loss of the others wander
+	monotone := batchSize == n && s.Optimizer == plainUpdate
+	var trace []Iteration
+	// rising counts the consecutive epochs in which the loss went up, and best
+	// is the lowest loss of any epoch
+	rising := 0
+	initialLoss := meanSquaredError(X, Y, unscale(theta))
+	best := math.Inf(1)
+	for epoch := 0; epoch < s.MaxEpochs; epoch++ {
+		if batchSize < n {
+			rng.Shuffle(n, func(i, j int) { order[i], order[j] = order[j], order[i] })
+		}
+		copy(prev, theta)
+		for start := 0; start < n; start += batchSize {
+			end := start + batchSize
+			if end > n {
+				end = n
+			}
+			// gradient of the mean squared error over the batch
+			for j := range grad {
+				grad[j] = 0
+			}
+			for _, i := range order[start:end] {
+				residual := Y[i] - dot(Z[i], theta)
+				for j := range grad {
+					grad[j] += -2 * Z[i][j] * residual / float64(end-start)
+				}
+			}
+			updates++
+			for j := range theta {
+				switch s.Optimizer {
+				case momentumUpdate:
+					velocity[j] = beta*velocity[j] + s.LearningRate*grad[j]
+					theta[j] -= velocity[j]
+				case adamUpdate:
+					m1[j] = beta1*m1[j] + (1-beta1)*grad[j]
+					m2[j] = beta2*m2[j] + (1-beta2)*grad[j]*grad[j]
+					mHat := m1[j] / (1 - math.Pow(beta1, float64(updates)))
+					vHat := m2[j] / (1 - math.Pow(beta2, float64(updates)))
+					theta[j] -= s.LearningRate * mHat / (math.Sqrt(vHat) + adamEps)
+				default:
+					theta[j] -= s.LearningRate * grad[j]
+				}
+			}
+		}
+		for j := range prev {
+			prev[j] = theta[j] - prev[j]
+		}
+		step := norm(prev)
+		if !isFinite(theta) {
+			return nil, &SolverError{Err: ErrNonFinite, Iteration: epoch, Detail: "parameters overflowed"}
+		}
+		orig := unscale(theta)
+		it := newIteration(epoch, step, s.LearningRate, meanSquaredError(X, Y, orig), orig)
+		if epoch > 0 && it.Loss > trace[epoch-1].Loss {
+			rising++
+		} else {
+			rising = 0
+		}
+		trace = append(trace, it)
+		if monotone && rising == divergenceWindow {
+			return nil, &SolverError{
+				Err:       ErrDiverged,
+				Iteration: epoch,
+				Detail:    fmt.Sprintf("loss rose for %d epochs in a row to %.8g", rising, it.Loss),
+			}
+		}
+		if !monotone && it.Loss > divergenceFactor*best && it.Loss > initialLoss {
+			return nil, &SolverError{
+				Err:       ErrDiverged,
+				Iteration: epoch,
+				Detail:    fmt.Sprintf("loss grew to %.8g, over %d times its lowest of %.8g", it.Loss, divergenceFactor, best),
+			}
+		}
+		best = math.Min(best, it.Loss)
+		if step < s.Epsilon*s.LearningRate {
+			orig := unscale(theta)
+			return &Result{Coef: orig[1:], Intercept: orig[0], Trace: trace}, nil
+		}
+	}
+	if batchSize == n {
+		return nil, &SolverError{
+			Err:       ErrNotConverged,
+			Iteration: len(trace),
+			Detail:    fmt.Sprintf("parameters still moving after %d epochs", len(trace)),
+		}
+	}
+	orig := unscale(theta)
+	return &Result{Coef: orig[1:], Intercept: orig[0], Trace: trace, Capped: true}, nil
+}
+
+// scaler standardizes the columns of a matrix to zero mean and unit variance,
+// using the population standard deviation
+type scaler struct {
+	Mean []float64
+	SD   []float64
+}
+
+// newScaler measures the mean and standard deviation of each column of X, with
+// each row weighted by W unless it is nil. A constant column cannot be
+// standardized and is reported as rank deficient
+func newScaler(X [][]float64, W []float64) (*scaler, error) {
+	n, p := sumWeights(W, len(X)), len(X[0])
+	sc := &scaler{Mean: make([]float64, p), SD: make([]float64, p)}
+	for j := 0; j < p; j++ {
+		col := column(X, j)
+		for i, x := range col {
+			sc.Mean[j] += weightAt(W, i) * x / n
+		}
+		for i, x := range col {
+			sc.SD[j] += weightAt(W, i) * (x - sc.Mean[j]) * (x - sc.Mean[j]) / n
+		}
+		sc.SD[j] = math.Sqrt(sc.SD[j])
+		if sc.SD[j] == 0 {
+			return nil, &SolverError{Err: ErrRankDeficient, Iteration: -1, Detail: fmt.Sprintf("predictor %d is constant and cannot be standardized", j+1)}
+		}
+	}
+	return sc, nil
+}
+
+// transform returns the standardized rows of X
+func (sc *scaler) transform(X [][]float64) [][]float64 {
+	Z := make([][]float64, len(X))
+	for i := range X {
+		Z[i] = make([]float64, len(X[i]))
+		for j := range X[i] {
+			Z[i][j] = (X[i][j] - sc.Mean[j]) / sc.SD[j]
+		}
+	}
+	return Z
+}
+
+// unscale maps the intercept and coefficients of a model fitted to the
+// standardized columns to those of the original columns
+func (sc *scaler) unscale(theta []float64) []float64 {
+	orig := make([]float64, len(theta))
+	orig[0] = theta[0]
+	for j := range sc.Mean {
+		orig[j+1] = theta[j+1] / sc.SD[j]
+		orig[0] -= theta[j+1] * sc.Mean[j] / sc.SD[j]
+	}
+	return orig
+}
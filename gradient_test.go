@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestGradientSolversMatchQR(t *testing.T) {
+	X, Y := readTestCSV(t, "advertising.csv", []string{"TV", "Radio", "Newspaper"}, "Sales")
+	coef, b, _, err := qrRegression(X, Y, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append([]float64{b}, coef...)
+	sc, err := newScaler(X, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the stochastic solvers wander around the minimum with a constant
+	// learning rate, so they are given a smaller one and a looser tolerance
+	tests := []struct {
+		name   string
+		solver gradientSolver
+		capped bool
+		tol    float64
+	}{
+		{"gd", gradientSolver{LearningRate: .1, Epsilon: 1e-6, MaxEpochs: 10000}, false, 1e-4},
+		{"momentum", gradientSolver{LearningRate: .1, Epsilon: 1e-6, MaxEpochs: 10000, Optimizer: momentumUpdate}, false, 1e-4},
+		{"adam", gradientSolver{LearningRate: .1, Epsilon: 1e-6, MaxEpochs: 10000, Optimizer: adamUpdate}, false, 1e-4},
+		{"sgd", gradientSolver{LearningRate: .001, Epsilon: 1e-6, MaxEpochs: 300, BatchSize: 1, Seed: 1}, true, .05},
+		{"minibatch", gradientSolver{LearningRate: .01, Epsilon: 1e-6, MaxEpochs: 1000, BatchSize: 32, Seed: 1}, true, .05},
+		{"adam minibatch", gradientSolver{LearningRate: .01, Epsilon: 1e-6, MaxEpochs: 1000, BatchSize: 32, Seed: 1, Optimizer: adamUpdate}, true, .05},
+	}
+	for _, tt := range tests {
+		result, err := tt.solver.Fit(X, Y)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if result.Capped != tt.capped {
+			t.Errorf("%s: capped %v, want %v", tt.name, result.Capped, tt.capped)
+		}
+		// compare on the scale of the standardized columns, where every
+		// coefficient moves the prediction by a similar amount
+		got := append([]float64{result.Intercept}, result.Coef...)
+		for j := range want {
+			scale := 1.0
+			if j > 0 {
+				scale = sc.SD[j-1]
+			}
+			if diff := math.Abs(got[j]-want[j]) * scale; diff > tt.tol*(1+math.Abs(want[j])*scale) {
+				t.Errorf("%s: parameter %d is %.8g, qr %.8g", tt.name, j, got[j], want[j])
+			}
+		}
+	}
+}
+
+func TestGradientSolverErrors(t *testing.T) {
+	X, Y := readTestCSV(t, "advertising.csv", []string{"TV", "Radio", "Newspaper"}, "Sales")
+	tests := []struct {
+		name      string
+		solver    gradientSolver
+		err       error
+		iteration int
+	}{
+		// a learning rate far above two over the largest eigenvalue of the
+		// hessian of the standardized problem overshoots further every epoch
+		{"gd diverges", gradientSolver{LearningRate: 5, Epsilon: 1e-6, MaxEpochs: 1000}, ErrDiverged, divergenceWindow},
+		{"momentum minibatch diverges", gradientSolver{LearningRate: 5, Epsilon: 1e-6, MaxEpochs: 1000, BatchSize: 32, Optimizer: momentumUpdate}, ErrDiverged, -1},
+		{"gd runs out of epochs", gradientSolver{LearningRate: .001, Epsilon: 1e-6, MaxEpochs: 5}, ErrNotConverged, 5},
+	}
+	for _, tt := range tests {
+		_, err := tt.solver.Fit(X, Y)
+		var serr *SolverError
+		if !errors.As(err, &serr) {
+			t.Fatalf("%s: got error %v, want a SolverError", tt.name, err)
+		}
+		if serr.Err != tt.err {
+			t.Errorf("%s: got %v, want %v", tt.name, serr.Err, tt.err)
+		}
+		if tt.iteration >= 0 && serr.Iteration != tt.iteration {
+			t.Errorf("%s: stopped at iteration %d, want %d", tt.name, serr.Iteration, tt.iteration)
+		}
+	}
+}
+
+func TestGradientSolverCapped(t *testing.T) {
+	X, Y := readTestCSV(t, "advertising.csv", []string{"TV", "Radio", "Newspaper"}, "Sales")
+	s := gradientSolver{LearningRate: .01, Epsilon: 1e-6, MaxEpochs: 3, BatchSize: 1, Seed: 1}
+	result, err := s.Fit(X, Y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Capped {
+		t.Error("sgd stopped by the epoch cap is not marked as capped")
+	}
+	if len(result.Trace) != s.MaxEpochs {
+		t.Errorf("trace has %d epochs, want %d", len(result.Trace), s.MaxEpochs)
+	}
+	last := result.Trace[len(result.Trace)-1].Theta
+	if last[0] != result.Intercept {
+		t.Errorf("capped intercept %g is not the last estimate %g", result.Intercept, last[0])
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// CoefficientStats holds the estimate and inference for a single parameter of
+// the model
+type CoefficientStats struct {
+	Name     string  `json:"name"`
+	Estimate float64 `json:"estimate"`
+	StdErr   float64 `json:"std_err"`
+	TStat    float64 `json:"t"`
+	// PValue is the two-sided p-value of the t-statistic
+	PValue float64 `json:"p_value"`
+	// Lower and Upper bound the confidence interval of the estimate
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+}
+
+// MarshalJSON implements json.Marshaler, writing each statistic that is NaN or
+// infinite, as those of a perfect fit are, as null
+func (c CoefficientStats) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Name     string   `json:"name"`
+		Estimate *float64 `json:"estimate"`
+		StdErr   *float64 `json:"std_err"`
+		TStat    *float64 `json:"t"`
+		PValue   *float64 `json:"p_value"`
+		Lower    *float64 `json:"lower"`
+		Upper    *float64 `json:"upper"`
+	}{c.Name, nullable(c.Estimate), nullable(c.StdErr), nullable(c.TStat), nullable(c.PValue), nullable(c.Lower), nullable(c.Upper)})
+}
+
+// UnmarshalJSON implements json.Unmarshaler, reading each null as NaN
+func (c *CoefficientStats) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		Name     string   `json:"name"`
+		Estimate *float64 `json:"estimate"`
+		StdErr   *float64 `json:"std_err"`
+		TStat    *float64 `json:"t"`
+		PValue   *float64 `json:"p_value"`
+		Lower    *float64 `json:"lower"`
+		Upper    *float64 `json:"upper"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	*c = CoefficientStats{
+		Name:     aux.Name,
+		Estimate: orNaN(aux.Estimate),
+		StdErr:   orNaN(aux.StdErr),
+		TStat:    orNaN(aux.TStat),
+		PValue:   orNaN(aux.PValue),
+		Lower:    orNaN(aux.Lower),
+		Upper:    orNaN(aux.Upper),
+	}
+	return nil
+}
+
+// coefficientCovariance returns the estimated covariance matrix of the
+// intercept followed by the coefficients, sigma^2 (X^T W X)^-1, along with the
+// residual degrees of freedom, where W is the diagonal of row weights or the
+// identity when W is nil. Rows with zero weight are not counted as
+// observations. (X^T W X)^-1 is recovered from the inverse of the
+// hessian of the mean squared error that newtons method uses, which is
+// 2/sum(W) X^T W X
+func coefficientCovariance(X [][]float64, Y, W []float64, coef []float64, b float64) ([][]float64, int, error) {
+	theta := append([]float64{b}, coef...)
+	n, k := weightedRows(W, len(X)), len(theta)
+	df := n - k
+	if df <= 0 {
+		return nil, 0, fmt.Errorf("inference needs more rows than the %d parameters but only %d have a positive weight", k, n)
+	}
+	hinv, err := invert(leastSquares{X: X, Y: Y, W: W}.Hessian(theta))
+	if err != nil {
+		return nil, 0, &SolverError{Err: ErrSingularHessian, Iteration: -1, Detail: err.Error()}
+	}
+	// the residual variance is the weighted sum of squared residuals over the
+	// degrees of freedom
+	sumW := sumWeights(W, len(X))
+	sigma2 := weightedMeanSquaredError(X, Y, W, theta) * sumW / float64(df)
+	for i := range hinv {
+		for j := range hinv[i] {
+			hinv[i][j] *= sigma2 * 2 / sumW
+		}
+	}
+	return hinv, df, nil
+}
+
+// coefficientInference returns the standard error, t-statistic, p-value and
+// the confidence interval at the given level of the intercept followed by each
+// coefficient, where names holds the name of each coefficient
+func coefficientInference(cov [][]float64, df int, coef []float64, b float64, names []string, level float64) []CoefficientStats {
+	theta := append([]float64{b}, coef...)
+	names = append([]string{"Intercept"}, names...)
+	tCrit := studentTQuantile(1-(1-level)/2, float64(df))
+	stats := make([]CoefficientStats, len(theta))
+	for j := range theta {
+		se := math.Sqrt(cov[j][j])
+		t := theta[j] / se
+		stats[j] = CoefficientStats{
+			Name:     names[j],
+			Estimate: theta[j],
+			StdErr:   se,
+			TStat:    t,
+			PValue:   2 * studentTCDF(-math.Abs(t), float64(df)),
+			Lower:    theta[j] - tCrit*se,
+			Upper:    theta[j] + tCrit*se,
+		}
+	}
+	return stats
+}
+
+// coefficientTable formats the coefficient statistics as a table with a row
+// per parameter
+func coefficientTable(stats []CoefficientStats, level float64) string {
+	return testTable(stats, level, "t")
+}
+
+// testTable formats the coefficient statistics as a table with a row per
+// parameter, naming the test statistic stat
+func testTable(stats []CoefficientStats, level float64, stat string) string {
+	width := len("Intercept")
+	for _, s := range stats {
+		if len(s.Name) > width {
+			width = len(s.Name)
+		}
+	}
+	lo, hi := (1-level)/2, 1-(1-level)/2
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%-*s %12s %12s %10s %10s %12s %12s\n", width, "", "coef", "std err", stat, "P>|"+stat+"|",
+		fmt.Sprintf("[%.3g", lo), fmt.Sprintf("%.3g]", hi))
+	for _, s := range stats {
+		fmt.Fprintf(&sb, "%-*s %12.6f %12.6f %10.3f %10.3f %12.6f %12.6f\n",
+			width, s.Name, s.Estimate, s.StdErr, s.TStat, s.PValue, s.Lower, s.Upper)
+	}
+	return sb.String()
+}
+
+// Interval is the range between a lower and an upper bound
+type Interval struct {
+	Lower float64
+	Upper float64
+}
+
+// predictIntervals predicts y at x along with the confidence interval of the
+// mean response and the prediction interval of a new observation, whose half
+// widths are crit standard errors. cov is the covariance of the intercept
+// followed by the coefficients and sigma2 the residual variance
+func predictIntervals(coef, x []float64, b float64, cov [][]float64, sigma2, crit float64) (float64, Interval, Interval) {
+	yhat := predict(coef, x, b)
+	// variance of the mean response is z^T cov z where z is the design row of x
+	z := append([]float64{1}, x...)
+	var meanVar float64
+	for i := range z {
+		for j := range z {
+			meanVar += z[i] * cov[i][j] * z[j]
+		}
+	}
+	confHalf := crit * math.Sqrt(meanVar)
+	predHalf := crit * math.Sqrt(meanVar+sigma2)
+	return yhat, Interval{yhat - confHalf, yhat + confHalf}, Interval{yhat - predHalf, yhat + predHalf}
+}
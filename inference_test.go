@@ -0,0 +1,74 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"gonum.org/v1/plot/plotter"
+)
+
+func TestPredictIntervalsSimpleRegression(t *testing.T) {
+	X, Y := readTestCSV(t, "advertising.csv", []string{"TV"}, "Sales")
+	coef, b, _, err := qrRegression(X, Y, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary := newFitSummary(X, Y, nil, coef, b, []string{"TV", "Sales"}, .95)
+
+	// the closed form of simple regression, yhat +- t s sqrt(1/n + (x-xbar)^2/Sxx)
+	// for the mean response and with one more inside the root for a new one
+	n := float64(len(X))
+	var xbar, sxx, rss float64
+	for i := range X {
+		xbar += X[i][0] / n
+	}
+	for i := range X {
+		sxx += (X[i][0] - xbar) * (X[i][0] - xbar)
+		r := Y[i] - predict(coef, X[i], b)
+		rss += r * r
+	}
+	s := math.Sqrt(rss / (n - 2))
+	crit := studentTQuantile(.975, n-2)
+	closeTo := func(got, want float64) bool { return math.Abs(got-want) <= 1e-9*(1+math.Abs(want)) }
+
+	for _, x := range []float64{0, 17.2, xbar, 150, 296.4, 400} {
+		yhat, conf, pred := predictIntervals(coef, []float64{x}, b, summary.Covariance, s*s, crit)
+		if !closeTo(yhat, b+coef[0]*x) {
+			t.Errorf("x %g: prediction %g, want %g", x, yhat, b+coef[0]*x)
+		}
+		spread := 1/n + (x-xbar)*(x-xbar)/sxx
+		confHalf, predHalf := crit*s*math.Sqrt(spread), crit*s*math.Sqrt(1+spread)
+		if !closeTo(conf.Lower, yhat-confHalf) || !closeTo(conf.Upper, yhat+confHalf) {
+			t.Errorf("x %g: confidence interval %v, want %g +- %g", x, conf, yhat, confHalf)
+		}
+		if !closeTo(pred.Lower, yhat-predHalf) || !closeTo(pred.Upper, yhat+predHalf) {
+			t.Errorf("x %g: prediction interval %v, want %g +- %g", x, pred, yhat, predHalf)
+		}
+	}
+
+	// the plotted bands run from the smallest to the largest x with the same
+	// intervals, the prediction band first
+	bands := intervalBands(X, coef, b, func(x float64) []float64 { return []float64{x} }, summary, .95)
+	if len(bands) != 2 {
+		t.Fatalf("%d bands, want 2", len(bands))
+	}
+	for k, band := range bands {
+		ends := []struct {
+			pt   plotter.XY
+			sign float64
+		}{{band.Lower[0], -1}, {band.Upper[len(band.Upper)-1], 1}}
+		for _, end := range ends {
+			x := end.pt.X
+			spread := 1/n + (x-xbar)*(x-xbar)/sxx
+			if k == 0 {
+				spread++
+			}
+			if want := b + coef[0]*x + end.sign*crit*s*math.Sqrt(spread); !closeTo(end.pt.Y, want) {
+				t.Errorf("%s band at x %g is %g, want %g", band.Label, x, end.pt.Y, want)
+			}
+		}
+	}
+	if x0, x1 := bands[0].Lower[0].X, bands[0].Lower[len(bands[0].Lower)-1].X; x0 != 0.7 || x1 != 296.4 {
+		t.Errorf("bands run from %g to %g, want the range of TV, 0.7 to 296.4", x0, x1)
+	}
+}
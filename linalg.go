@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+// errSingularMatrix is returned when a linear system has no unique solution
+var errSingularMatrix = errors.New("matrix is singular to working precision")
+
+// singularTolerance is the size of a pivot relative to its column below which
+// solveLinear treats a matrix as singular
+const singularTolerance = 1e-12
+
+// solveLinear solves the square system A*x = b using gaussian elimination with
+// partial pivoting. Neither A nor b are modified. A pivot counts as zero when
+// it is below singularTolerance times the largest entry of its column in A, so
+// the test does not depend on the units of the columns
+func solveLinear(A [][]float64, b []float64) ([]float64, error) {
+	n := len(b)
+	// build the augmented matrix [A|b] so the inputs are left untouched
+	aug := make([][]float64, n)
+	for i := range A {
+		aug[i] = make([]float64, n+1)
+		copy(aug[i], A[i])
+		aug[i][n] = b[i]
+	}
+	// the size of each column, against which its pivot is judged
+	size := make([]float64, n)
+	for i := range A {
+		for j := range size {
+			size[j] = math.Max(size[j], math.Abs(A[i][j]))
+		}
+	}
+	for col := 0; col < n; col++ {
+		// swap the row with the largest pivot into place
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(aug[row][col]) > math.Abs(aug[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(aug[pivot][col]) <= singularTolerance*size[col] {
+			return nil, errSingularMatrix
+		}
+		aug[col], aug[pivot] = aug[pivot], aug[col]
+		// eliminate the column from every row below the pivot
+		for row := col + 1; row < n; row++ {
+			f := aug[row][col] / aug[col][col]
+			for k := col; k <= n; k++ {
+				aug[row][k] -= f * aug[col][k]
+			}
+		}
+	}
+	// back substitute
+	x := make([]float64, n)
+	for i := n - 1; i >= 0; i-- {
+		sum := aug[i][n]
+		for k := i + 1; k < n; k++ {
+			sum -= aug[i][k] * x[k]
+		}
+		x[i] = sum / aug[i][i]
+	}
+	return x, nil
+}
+
+// dot returns the inner product of two equally sized vectors
+func dot(a, b []float64) (sum float64) {
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return
+}
+
+// norm returns the euclidean length of a vector
+func norm(a []float64) float64 {
+	return math.Sqrt(dot(a, a))
+}
+
+// column returns a copy of the j'th column of the row major matrix X
+func column(X [][]float64, j int) []float64 {
+	col := make([]float64, len(X))
+	for i := range X {
+		col[i] = X[i][j]
+	}
+	return col
+}
+
+// householderQR factors the n by k matrix A (n >= k) as A = QR using
+// householder reflections. Rather than forming Q, the reflections are applied
+// to b so the returned vector is Q^T*b. Only the upper k by k triangle of the
+// returned matrix is meaningful. Neither A nor b are modified
+func householderQR(A [][]float64, b []float64) ([][]float64, []float64) {
+	n, k := len(A), len(A[0])
+	R := make([][]float64, n)
+	for i := range A {
+		R[i] = append([]float64{}, A[i]...)
+	}
+	qtb := append([]float64{}, b...)
+	v := make([]float64, n)
+	for j := 0; j < k; j++ {
+		// build the reflector v that zeroes column j below the diagonal
+		var alpha float64
+		for i := j; i < n; i++ {
+			alpha += R[i][j] * R[i][j]
+		}
+		alpha = math.Sqrt(alpha)
+		if alpha == 0 {
+			continue
+		}
+		if R[j][j] > 0 {
+			alpha = -alpha
+		}
+		var vnorm2 float64
+		for i := j; i < n; i++ {
+			v[i] = R[i][j]
+			if i == j {
+				v[i] -= alpha
+			}
+			vnorm2 += v[i] * v[i]
+		}
+		if vnorm2 == 0 {
+			continue
+		}
+		// apply H = I - 2vv^T/(v^Tv) to the remaining columns and to b
+		for c := j; c < k; c++ {
+			var s float64
+			for i := j; i < n; i++ {
+				s += v[i] * R[i][c]
+			}
+			s = 2 * s / vnorm2
+			for i := j; i < n; i++ {
+				R[i][c] -= s * v[i]
+			}
+		}
+		var s float64
+		for i := j; i < n; i++ {
+			s += v[i] * qtb[i]
+		}
+		s = 2 * s / vnorm2
+		for i := j; i < n; i++ {
+			qtb[i] -= s * v[i]
+		}
+	}
+	return R[:k], qtb[:k]
+}
+
+// symEigenvalues returns the eigenvalues of the symmetric matrix A in
+// ascending order using the cyclic jacobi method. A is not modified
+func symEigenvalues(A [][]float64) []float64 {
+	n := len(A)
+	a := make([][]float64, n)
+	for i := range A {
+		a[i] = append([]float64{}, A[i]...)
+	}
+	for sweep := 0; sweep < 100; sweep++ {
+		var off float64
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				off += a[i][j] * a[i][j]
+			}
+		}
+		if off < 1e-30 {
+			break
+		}
+		for p := 0; p < n; p++ {
+			for q := p + 1; q < n; q++ {
+				if a[p][q] == 0 {
+					continue
+				}
+				// rotate rows and columns p and q to zero a[p][q]
+				theta := (a[q][q] - a[p][p]) / (2 * a[p][q])
+				t := 1 / (math.Abs(theta) + math.Sqrt(theta*theta+1))
+				if theta < 0 {
+					t = -t
+				}
+				c := 1 / math.Sqrt(t*t+1)
+				s := t * c
+				for r := 0; r < n; r++ {
+					arp, arq := a[r][p], a[r][q]
+					a[r][p] = c*arp - s*arq
+					a[r][q] = s*arp + c*arq
+				}
+				for r := 0; r < n; r++ {
+					apr, aqr := a[p][r], a[q][r]
+					a[p][r] = c*apr - s*aqr
+					a[q][r] = s*apr + c*aqr
+				}
+			}
+		}
+	}
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = a[i][i]
+	}
+	sort.Float64s(values)
+	return values
+}
+
+// invert returns the inverse of the square matrix A by solving against each
+// column of the identity
+func invert(A [][]float64) ([][]float64, error) {
+	n := len(A)
+	inv := make([][]float64, n)
+	for i := range inv {
+		inv[i] = make([]float64, n)
+	}
+	e := make([]float64, n)
+	for j := 0; j < n; j++ {
+		for i := range e {
+			e[i] = 0
+		}
+		e[j] = 1
+		col, err := solveLinear(A, e)
+		if err != nil {
+			return nil, err
+		}
+		for i := range col {
+			inv[i][j] = col[i]
+		}
+	}
+	return inv, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSymEigenvalues(t *testing.T) {
+	tests := []struct {
+		A    [][]float64
+		want []float64
+	}{
+		{[][]float64{{2, 1}, {1, 2}}, []float64{1, 3}},
+		{[][]float64{{4, 0, 0}, {0, -1, 0}, {0, 0, 2}}, []float64{-1, 2, 4}},
+		// the second difference matrix has eigenvalues 2 - 2cos(k pi/4)
+		{[][]float64{{2, -1, 0}, {-1, 2, -1}, {0, -1, 2}}, []float64{2 - math.Sqrt2, 2, 2 + math.Sqrt2}},
+		{[][]float64{{1, 2, 3}, {2, 4, 6}, {3, 6, 9}}, []float64{0, 0, 14}},
+	}
+	for _, tt := range tests {
+		got := symEigenvalues(tt.A)
+		for i := range tt.want {
+			if math.Abs(got[i]-tt.want[i]) > 1e-10 {
+				t.Errorf("symEigenvalues(%v) = %v, want %v", tt.A, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestHouseholderQR(t *testing.T) {
+	// R^T R = A^T A and the first columns of Q^T b solve the least squares
+	// problem, here fitted exactly by x = (1, 2)
+	A := [][]float64{{1, 1}, {1, 2}, {1, 3}, {1, 4}}
+	b := []float64{3, 5, 7, 9}
+	R, qtb := householderQR(A, b)
+	x1 := qtb[1] / R[1][1]
+	x0 := (qtb[0] - R[0][1]*x1) / R[0][0]
+	if math.Abs(x0-1) > 1e-12 || math.Abs(x1-2) > 1e-12 {
+		t.Errorf("householderQR solution is (%g, %g), want (1, 2)", x0, x1)
+	}
+	ata := [][]float64{{4, 10}, {10, 30}}
+	for i := range ata {
+		for j := range ata[i] {
+			var rtr float64
+			for k := range R {
+				rtr += R[k][i] * R[k][j]
+			}
+			if math.Abs(rtr-ata[i][j]) > 1e-12 {
+				t.Errorf("(R^T R)[%d][%d] = %g, want %g", i, j, rtr, ata[i][j])
+			}
+		}
+	}
+}
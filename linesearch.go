@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// lineSearch selects how far along the newton direction each step goes
+type lineSearch int
+
+const (
+	// noLineSearch always takes the full newton step
+	noLineSearch lineSearch = iota
+	// armijoLineSearch backtracks from the full step until the objective
+	// decreases sufficiently
+	armijoLineSearch
+	// wolfeLineSearch brackets and bisects a step satisfying the strong wolfe
+	// conditions, sufficient decrease along with a flattened slope
+	wolfeLineSearch
+)
+
+const (
+	// armijoC1 is the fraction of the predicted decrease that a step must
+	// achieve to be accepted
+	armijoC1 = 1e-4
+	// wolfeC2 is the fraction of the initial slope the slope at an accepted
+	// step may have in absolute value
+	wolfeC2 = 0.9
+	// backtrackFactor shrinks the step after each rejected armijo trial
+	backtrackFactor = 0.5
+	// maxLineSearchSteps bounds the trials made by a single search
+	maxLineSearchSteps = 50
+)
+
+// parseLineSearch returns the line search named by s
+func parseLineSearch(s string) (lineSearch, error) {
+	switch s {
+	case "none":
+		return noLineSearch, nil
+	case "armijo":
+		return armijoLineSearch, nil
+	case "wolfe":
+		return wolfeLineSearch, nil
+	}
+	return noLineSearch, fmt.Errorf("unknown line search %q, must be none, armijo or wolfe", s)
+}
+
+// search returns the step length along the descent direction d from theta,
+// where f and g are the objective and its gradient at theta
+func (ls lineSearch) search(obj objective, theta []float64, f float64, g, d []float64) (float64, error) {
+	slope := dot(g, d)
+	if slope >= 0 {
+		return 0, fmt.Errorf("direction is not a descent direction, slope %.3g", slope)
+	}
+	switch ls {
+	case armijoLineSearch:
+		return armijoSearch(obj, theta, f, slope, d)
+	case wolfeLineSearch:
+		return wolfeSearch(obj, theta, f, slope, d)
+	}
+	return 1, nil
+}
+
+// armijoSearch backtracks from a unit step until the armijo sufficient
+// decrease condition holds
+func armijoSearch(obj objective, theta []float64, f, slope float64, d []float64) (float64, error) {
+	alpha := 1.0
+	for i := 0; i < maxLineSearchSteps; i++ {
+		if obj.Value(axpy(alpha, d, theta)) <= f+armijoC1*alpha*slope {
+			return alpha, nil
+		}
+		alpha *= backtrackFactor
+	}
+	return 0, fmt.Errorf("armijo line search found no sufficient decrease after %d trials", maxLineSearchSteps)
+}
+
+// wolfeSearch finds a step satisfying the strong wolfe conditions by
+// expanding from a unit step until a minimum is bracketed and then bisecting
+// the bracket, following algorithms 3.5 and 3.6 of Nocedal and Wright
+func wolfeSearch(obj objective, theta []float64, f0, slope0 float64, d []float64) (float64, error) {
+	// phi evaluates the objective and its slope along d at step length a
+	phi := func(a float64) (float64, float64) {
+		x := axpy(a, d, theta)
+		return obj.Value(x), dot(obj.Gradient(x), d)
+	}
+	zoom := func(lo, hi, fLo float64) (float64, error) {
+		for i := 0; i < maxLineSearchSteps; i++ {
+			a := (lo + hi) / 2
+			f, slope := phi(a)
+			if f > f0+armijoC1*a*slope0 || f >= fLo {
+				hi = a
+				continue
+			}
+			if math.Abs(slope) <= -wolfeC2*slope0 {
+				return a, nil
+			}
+			if slope*(hi-lo) >= 0 {
+				hi = lo
+			}
+			lo, fLo = a, f
+		}
+		return 0, fmt.Errorf("wolfe line search failed to narrow the bracket after %d trials", maxLineSearchSteps)
+	}
+	prevA, prevF := 0.0, f0
+	a := 1.0
+	for i := 0; i < maxLineSearchSteps; i++ {
+		f, slope := phi(a)
+		if f > f0+armijoC1*a*slope0 || (i > 0 && f >= prevF) {
+			return zoom(prevA, a, prevF)
+		}
+		if math.Abs(slope) <= -wolfeC2*slope0 {
+			return a, nil
+		}
+		if slope >= 0 {
+			return zoom(a, prevA, f)
+		}
+		prevA, prevF = a, f
+		a *= 2
+	}
+	return 0, fmt.Errorf("wolfe line search found no bracket after %d trials", maxLineSearchSteps)
+}
+
+// axpy returns a*x + y as a new vector
+func axpy(a float64, x, y []float64) []float64 {
+	z := make([]float64, len(y))
+	for i := range y {
+		z[i] = a*x[i] + y[i]
+	}
+	return z
+}
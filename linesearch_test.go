@@ -0,0 +1,83 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// lineSearchCase is an objective with a point to search from and the multiple
+// of the steepest descent direction to search along
+type lineSearchCase struct {
+	name  string
+	obj   objective
+	theta []float64
+	scale float64
+}
+
+// lineSearchCases returns a quadratic and a log loss, each with a direction
+// whose unit step is too long, too short and about right
+func lineSearchCases() []lineSearchCase {
+	X := [][]float64{{1}, {2}, {3}, {4}, {5}}
+	quadratic := leastSquares{X: X, Y: []float64{2.1, 3.9, 6.2, 7.8, 10.1}}
+	logistic := logLoss{X: X, Y: []float64{0, 1, 0, 1, 1}}
+	var cases []lineSearchCase
+	for _, scale := range []float64{10, 0.001, 0.2} {
+		cases = append(cases,
+			lineSearchCase{"quadratic", quadratic, []float64{0, 0}, scale},
+			lineSearchCase{"log loss", logistic, []float64{1, -1}, scale})
+	}
+	return cases
+}
+
+func TestLineSearchConditions(t *testing.T) {
+	for _, tt := range lineSearchCases() {
+		g := tt.obj.Gradient(tt.theta)
+		d := make([]float64, len(g))
+		for j := range g {
+			d[j] = -tt.scale * g[j]
+		}
+		f0, slope0 := tt.obj.Value(tt.theta), dot(g, d)
+		searches := []struct {
+			name   string
+			search func(objective, []float64, float64, float64, []float64) (float64, error)
+		}{
+			{"armijo", armijoSearch},
+			{"wolfe", wolfeSearch},
+		}
+		for _, ls := range searches {
+			alpha, err := ls.search(tt.obj, tt.theta, f0, slope0, d)
+			if err != nil {
+				t.Fatalf("%s scale %g %s: %v", tt.name, tt.scale, ls.name, err)
+			}
+			x := axpy(alpha, d, tt.theta)
+			if f := tt.obj.Value(x); alpha <= 0 || f > f0+armijoC1*alpha*slope0 {
+				t.Errorf("%s scale %g %s: step %g gives %g, no sufficient decrease from %g", tt.name, tt.scale, ls.name, alpha, f, f0)
+			}
+			if tt.scale > 1 && alpha >= 1 {
+				t.Errorf("%s scale %g %s: took step %g of an overlong direction", tt.name, tt.scale, ls.name, alpha)
+			}
+			if ls.name != "wolfe" {
+				continue
+			}
+			// only the wolfe search lengthens a short direction
+			if tt.scale < 0.01 && alpha <= 1 {
+				t.Errorf("%s scale %g: wolfe step %g did not expand", tt.name, tt.scale, alpha)
+			}
+			if slope := dot(tt.obj.Gradient(x), d); math.Abs(slope) > -wolfeC2*slope0 {
+				t.Errorf("%s scale %g: wolfe step %g has slope %g, initial slope %g", tt.name, tt.scale, alpha, slope, slope0)
+			}
+		}
+	}
+}
+
+func TestLineSearchAscent(t *testing.T) {
+	for _, tt := range lineSearchCases() {
+		// the gradient itself points uphill
+		g := tt.obj.Gradient(tt.theta)
+		for _, ls := range []lineSearch{noLineSearch, armijoLineSearch, wolfeLineSearch} {
+			if _, err := ls.search(tt.obj, tt.theta, tt.obj.Value(tt.theta), g, g); err == nil {
+				t.Errorf("%s line search %d accepted an ascent direction", tt.name, ls)
+			}
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// sigmoid returns the probability 1/(1+e^-z) of the log odds z
+func sigmoid(z float64) float64 {
+	if z >= 0 {
+		return 1 / (1 + math.Exp(-z))
+	}
+	e := math.Exp(z)
+	return e / (1 + e)
+}
+
+// softplus returns log(1+e^z) without overflowing for large z
+func softplus(z float64) float64 {
+	if z > 0 {
+		return z + math.Log1p(math.Exp(-z))
+	}
+	return math.Log1p(math.Exp(z))
+}
+
+// logLoss is the mean negative log-likelihood of a logistic model of the 0/1
+// response Y on the columns of X, whose log odds are linear in X. When W is set
+// the loss of each row is weighted by W and the mean is taken over the sum of
+// the weights
+type logLoss struct {
+	X [][]float64
+	Y []float64
+	W []float64
+}
+
+// Value implements objective. The loss of a row with log odds z is
+// log(1+e^z) - y*z
+func (o logLoss) Value(theta []float64) float64 {
+	var loss float64
+	n := sumWeights(o.W, len(o.X))
+	for i := range o.X {
+		z := predict(theta[1:], o.X[i], theta[0])
+		loss += weightAt(o.W, i) * (softplus(z) - o.Y[i]*z) / n
+	}
+	return loss
+}
+
+// Gradient implements objective
+func (o logLoss) Gradient(theta []float64) []float64 {
+	grad := make([]float64, len(theta))
+	z := make([]float64, len(theta))
+	z[0] = 1
+	n := sumWeights(o.W, len(o.X))
+	for i := range o.X {
+		copy(z[1:], o.X[i])
+		residual := sigmoid(dot(z, theta)) - o.Y[i]
+		w := weightAt(o.W, i)
+		for j := range z {
+			grad[j] += w * z[j] * residual / n
+		}
+	}
+	return grad
+}
+
+// Hessian implements objective. Each row contributes its design row times its
+// transpose scaled by the variance p(1-p) of its predicted probability
+func (o logLoss) Hessian(theta []float64) [][]float64 {
+	k := len(theta)
+	hess := make([][]float64, k)
+	for j := range hess {
+		hess[j] = make([]float64, k)
+	}
+	z := make([]float64, k)
+	z[0] = 1
+	n := sumWeights(o.W, len(o.X))
+	for i := range o.X {
+		copy(z[1:], o.X[i])
+		p := sigmoid(dot(z, theta))
+		v := weightAt(o.W, i) * p * (1 - p) / n
+		for j := range z {
+			for l := range z {
+				hess[j][l] += v * z[j] * z[l]
+			}
+		}
+	}
+	return hess
+}
+
+// checkBinary returns an error unless every response is 0 or 1 and both occur
+func checkBinary(Y []float64) error {
+	var seen [2]bool
+	for i, y := range Y {
+		if y != 0 && y != 1 {
+			return fmt.Errorf("a binomial response must be 0 or 1 but row %d is %g", i, y)
+		}
+		seen[int(y)] = true
+	}
+	if !seen[0] || !seen[1] {
+		return fmt.Errorf("a binomial response needs both 0s and 1s")
+	}
+	return nil
+}
+
+// logisticSolver fits a logistic regression by newtons method on the log loss,
+// which is iteratively reweighted least squares, stopping once the step
+// magnitude drops below Epsilon or failing after MaxIter iterations. The
+// coefficients are those of the log odds
+type logisticSolver struct {
+	Epsilon    float64
+	MaxIter    int
+	LineSearch lineSearch
+}
+
+// Fit implements Solver
+func (s logisticSolver) Fit(X [][]float64, Y []float64) (*Result, error) {
+	return s.FitWeighted(X, Y, nil)
+}
+
+// FitWeighted implements weightedSolver
+func (s logisticSolver) FitWeighted(X [][]float64, Y, W []float64) (*Result, error) {
+	if err := checkFinite(X, Y); err != nil {
+		return nil, err
+	}
+	if err := checkBinary(Y); err != nil {
+		return nil, err
+	}
+	theta, trace, err := minimizeNewton(logLoss{X: X, Y: Y, W: W}, make([]float64, len(X[0])+1), s.Epsilon, s.MaxIter, s.LineSearch)
+	if err != nil {
+		// the log odds grow without bound when a predictor separates the
+		// classes, flattening the hessian until it is singular
+		if serr, ok := err.(*SolverError); ok && (serr.Err == ErrSingularHessian || serr.Err == ErrNotConverged) {
+			serr.Detail += ", the classes may be perfectly separated by the predictors"
+		}
+		return nil, err
+	}
+	return &Result{Coef: theta[1:], Intercept: theta[0], Trace: trace}, nil
+}
+
+// predictProbabilities returns the probability of a 1 the logistic model gives
+// each row of X
+func predictProbabilities(X [][]float64, coef []float64, b float64) []float64 {
+	probs := make([]float64, len(X))
+	for i := range X {
+		probs[i] = sigmoid(predict(coef, X[i], b))
+	}
+	return probs
+}
+
+// confusionMatrix counts the rows, or sums their weights, by actual and
+// predicted class
+type confusionMatrix struct {
+	TrueNegative  float64 `json:"true_negative"`
+	FalsePositive float64 `json:"false_positive"`
+	FalseNegative float64 `json:"false_negative"`
+	TruePositive  float64 `json:"true_positive"`
+}
+
+// classification holds the measures of how well predicted probabilities
+// separate a 0/1 response. Rows are predicted to be 1 when their probability is
+// at least Threshold
+type classification struct {
+	Threshold float64         `json:"threshold"`
+	LogLoss   float64         `json:"log_loss"`
+	Accuracy  float64         `json:"accuracy"`
+	AUC       float64         `json:"auc"`
+	Confusion confusionMatrix `json:"confusion_matrix"`
+}
+
+// newClassification measures the probabilities probs of the 0/1 response Y at
+// the threshold, weighting each row by W when it is not nil
+func newClassification(Y, probs, W []float64, threshold float64) classification {
+	c := classification{Threshold: threshold}
+	n := sumWeights(W, len(Y))
+	for i, y := range Y {
+		w := weightAt(W, i)
+		// clamp the probabilities so a confident mistake costs a large but
+		// finite loss
+		p := math.Max(1e-15, math.Min(1-1e-15, probs[i]))
+		c.LogLoss -= w * (y*math.Log(p) + (1-y)*math.Log(1-p)) / n
+		predicted := probs[i] >= threshold
+		switch {
+		case y == 1 && predicted:
+			c.Confusion.TruePositive += w
+		case y == 1:
+			c.Confusion.FalseNegative += w
+		case predicted:
+			c.Confusion.FalsePositive += w
+		default:
+			c.Confusion.TrueNegative += w
+		}
+	}
+	c.Accuracy = (c.Confusion.TruePositive + c.Confusion.TrueNegative) / n
+	c.AUC = auc(Y, probs, W)
+	return c
+}
+
+// auc returns the area under the ROC curve of the probabilities, the chance
+// that a random 1 gets a higher probability than a random 0 with ties counting
+// half, with each pair weighted by the product of the weights of its rows
+func auc(Y, probs, W []float64) float64 {
+	order := make([]int, len(Y))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return probs[order[a]] < probs[order[b]] })
+	// sweep up through the probabilities a tie at a time, crediting each
+	// positive with the negatives below it and half of those tied with it
+	var below, pairs, positives float64
+	for start := 0; start < len(order); {
+		end := start
+		var tiedPos, tiedNeg float64
+		for end < len(order) && probs[order[end]] == probs[order[start]] {
+			i := order[end]
+			if Y[i] == 1 {
+				tiedPos += weightAt(W, i)
+			} else {
+				tiedNeg += weightAt(W, i)
+			}
+			end++
+		}
+		pairs += tiedPos * (below + tiedNeg/2)
+		below += tiedNeg
+		positives += tiedPos
+		start = end
+	}
+	return pairs / (positives * below)
+}
+
+// String formats the measures with the confusion matrix laid out with a row
+// per actual class
+func (c classification) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Log-Loss: %.8f\n", c.LogLoss)
+	fmt.Fprintf(&sb, "Accuracy: %.8f\n", c.Accuracy)
+	fmt.Fprintf(&sb, "AUC: %.8f\n", c.AUC)
+	fmt.Fprintf(&sb, "Confusion Matrix (threshold %g):\n", c.Threshold)
+	fmt.Fprintf(&sb, "%-10s%14s%14s\n", "", "predicted 0", "predicted 1")
+	fmt.Fprintf(&sb, "%-10s%14g%14g\n", "actual 0", c.Confusion.TrueNegative, c.Confusion.FalsePositive)
+	fmt.Fprintf(&sb, "%-10s%14g%14g\n", "actual 1", c.Confusion.FalseNegative, c.Confusion.TruePositive)
+	return sb.String()
+}
+
+// newLogisticSummary computes the summary of the logistic model with
+// coefficients coef and intercept b on the data, where names holds the name of
+// each predictor followed by the response. RSquared is McFadden's pseudo
+// R-squared, one minus the log-likelihood over that of the intercept alone,
+// and AdjRSquared penalises it by the number of parameters. The coefficient
+// inference is the wald test with the covariance taken from the inverse of the
+// hessian of the log-likelihood. Rows with zero weight are not counted as
+// observations
+func newLogisticSummary(X [][]float64, Y, W []float64, coef []float64, b float64, names []string, level float64) FitSummary {
+	n := weightedRows(W, len(X))
+	k := len(coef) + 1
+	sumW := sumWeights(W, len(X))
+	theta := append([]float64{b}, coef...)
+	obj := logLoss{X: X, Y: Y, W: W}
+	var mean float64
+	for i, y := range Y {
+		mean += weightAt(W, i) * y / sumW
+	}
+	ll := -sumW * obj.Value(theta)
+	llNull := -sumW * (-mean*math.Log(mean) - (1-mean)*math.Log(1-mean))
+	s := FitSummary{
+		Family:        "binomial",
+		Response:      names[len(names)-1],
+		N:             n,
+		K:             k,
+		DFModel:       k - 1,
+		DFResid:       n - k,
+		RSquared:      1 - ll/llNull,
+		AdjRSquared:   1 - (ll-float64(k))/llNull,
+		LogLikelihood: ll,
+		AIC:           -2*ll + 2*float64(k),
+		BIC:           -2*ll + float64(k)*math.Log(float64(n)),
+		MAE:           math.NaN(),
+		Level:         level,
+	}
+	if cov, err := invert(obj.Hessian(theta)); err == nil {
+		for i := range cov {
+			for j := range cov[i] {
+				cov[i][j] /= sumW
+			}
+		}
+		s.Coefficients = waldInference(cov, coef, b, names[:len(coef)], level)
+		s.Covariance = cov
+	}
+	return s
+}
+
+// waldInference returns the standard error, z-statistic, p-value and the
+// confidence interval at the given level of the intercept followed by each
+// coefficient from the normal approximation to their sampling distribution
+func waldInference(cov [][]float64, coef []float64, b float64, names []string, level float64) []CoefficientStats {
+	theta := append([]float64{b}, coef...)
+	names = append([]string{"Intercept"}, names...)
+	zCrit := normalQuantile(1 - (1-level)/2)
+	stats := make([]CoefficientStats, len(theta))
+	for j := range theta {
+		se := math.Sqrt(cov[j][j])
+		z := theta[j] / se
+		stats[j] = CoefficientStats{
+			Name:     names[j],
+			Estimate: theta[j],
+			StdErr:   se,
+			TStat:    z,
+			PValue:   math.Erfc(math.Abs(z) / math.Sqrt2),
+			Lower:    theta[j] - zCrit*se,
+			Upper:    theta[j] + zCrit*se,
+		}
+	}
+	return stats
+}
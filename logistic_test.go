@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestAUC(t *testing.T) {
+	tests := []struct {
+		Y, probs, W []float64
+		want        float64
+	}{
+		{[]float64{0, 0, 1, 1}, []float64{0.1, 0.2, 0.7, 0.9}, nil, 1},
+		{[]float64{1, 1, 0, 0}, []float64{0.1, 0.2, 0.7, 0.9}, nil, 0},
+		{[]float64{0, 1, 0, 1}, []float64{0.5, 0.5, 0.5, 0.5}, nil, 0.5},
+		// of the nine pairs of a positive and a negative, the positive is
+		// above in six and tied in two, which count a half each
+		{[]float64{0, 0, 1, 1, 0, 1}, []float64{0.1, 0.4, 0.4, 0.8, 0.8, 0.9}, nil, 7.0 / 9},
+		// doubling the weight of the negative at 0.8 adds a tie with the positive
+		// there and a pair below the positive at 0.9
+		{[]float64{0, 0, 1, 1, 0, 1}, []float64{0.1, 0.4, 0.4, 0.8, 0.8, 0.9}, []float64{1, 1, 1, 1, 2, 1}, 8.5 / 12},
+	}
+	for _, tt := range tests {
+		if got := auc(tt.Y, tt.probs, tt.W); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("auc(%v, %v, %v) = %.12g, want %.12g", tt.Y, tt.probs, tt.W, got, tt.want)
+		}
+	}
+}
+
+func TestLogisticTwoGroups(t *testing.T) {
+	// with a single 0/1 predictor the model fits the share of ones in each
+	// group, 2 of 6 at x = 0 and 6 of 8 at x = 1, so the intercept and slope
+	// are log odds and the wald standard errors are those of the 2x2 table
+	var X [][]float64
+	var Y []float64
+	for _, g := range []struct{ x, ones, n int }{{0, 2, 6}, {1, 6, 8}} {
+		for i := 0; i < g.n; i++ {
+			X = append(X, []float64{float64(g.x)})
+			y := 0.0
+			if i < g.ones {
+				y = 1
+			}
+			Y = append(Y, y)
+		}
+	}
+	logit := func(p float64) float64 { return math.Log(p / (1 - p)) }
+	b, m := logit(2.0/6), logit(6.0/8)-logit(2.0/6)
+	result, err := logisticSolver{Epsilon: 1e-10, MaxIter: 100, LineSearch: armijoLineSearch}.Fit(X, Y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(result.Intercept-b) > 1e-8 || math.Abs(result.Coef[0]-m) > 1e-8 {
+		t.Fatalf("log odds %.10g + %.10gx, want %.10g + %.10gx", result.Intercept, result.Coef[0], b, m)
+	}
+
+	s := newLogisticSummary(X, Y, nil, result.Coef, result.Intercept, []string{"x", "y"}, 0.95)
+	// the log-likelihood sums n(p log p + (1-p) log(1-p)) over the groups,
+	// and the null model has p = 8/14 throughout
+	bernoulli := func(n, p float64) float64 { return n * (p*math.Log(p) + (1-p)*math.Log(1-p)) }
+	ll, llNull := bernoulli(6, 2.0/6)+bernoulli(8, 6.0/8), bernoulli(14, 8.0/14)
+	if s.N != 14 || s.K != 2 || math.Abs(s.LogLikelihood-ll) > 1e-9 || math.Abs(s.RSquared-(1-ll/llNull)) > 1e-9 {
+		t.Errorf("n %d, k %d, log-likelihood %.10g and pseudo R-squared %.10g, want 14, 2, %.10g and %.10g", s.N, s.K, s.LogLikelihood, s.RSquared, ll, 1-ll/llNull)
+	}
+	if math.Abs(s.AIC-(-2*ll+4)) > 1e-9 || math.Abs(s.BIC-(-2*ll+2*math.Log(14))) > 1e-9 {
+		t.Errorf("AIC %.10g and BIC %.10g, want %.10g and %.10g", s.AIC, s.BIC, -2*ll+4, -2*ll+2*math.Log(14))
+	}
+	want := []float64{math.Sqrt(1.0/2 + 1.0/4), math.Sqrt(1.0/2 + 1.0/4 + 1.0/6 + 1.0/2)}
+	z := normalQuantile(0.975)
+	for j, c := range s.Coefficients {
+		if math.Abs(c.StdErr-want[j]) > 1e-8 {
+			t.Errorf("%s std err %.10g, want %.10g", c.Name, c.StdErr, want[j])
+		}
+		if math.Abs(c.Upper-c.Lower-2*z*want[j]) > 1e-8 {
+			t.Errorf("%s interval [%.10g, %.10g] is not %.10g wide", c.Name, c.Lower, c.Upper, 2*z*want[j])
+		}
+		if wantP := math.Erfc(math.Abs(c.Estimate/want[j]) / math.Sqrt2); math.Abs(c.PValue-wantP) > 1e-8 {
+			t.Errorf("%s p-value %.10g, want %.10g", c.Name, c.PValue, wantP)
+		}
+	}
+}
+
+func TestBinomialFlagConflicts(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-alpha", "1"}, "cannot be combined with -alpha 1"},
+		{[]string{"-solver", "qr"}, "cannot be combined with -solver qr"},
+		{[]string{"-solver", "qr", "-robust", "huber"}, "cannot be combined with -solver qr or -robust huber"},
+		{[]string{"-quantile", "0.5"}, "cannot be combined with -quantile 0.5"},
+	}
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("fit", flag.ContinueOnError)
+		s := newSolverFlags(fs)
+		if err := fs.Parse(append([]string{"-family", "binomial"}, tt.args...)); err != nil {
+			t.Fatal(err)
+		}
+		_, err := s.solver()
+		if err == nil || !strings.HasSuffix(err.Error(), tt.want) {
+			t.Errorf("%q: error %v, want it to end %q", tt.args, err, tt.want)
+		}
+	}
+	fs := flag.NewFlagSet("fit", flag.ContinueOnError)
+	s := newSolverFlags(fs)
+	if err := fs.Parse([]string{"-family", "binomial", "-solver", "newton"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.solver(); err != nil {
+		t.Errorf("binomial with the newton solver: %v", err)
+	}
+}
@@ -1,87 +1,230 @@
 package main
 
 import (
-	"encoding/csv"
-	"flag"
 	"fmt"
+	"image/color"
 	"log"
 	"math"
+	"math/rand"
 	"os"
+	"strings"
 
 	"gonum.org/v1/plot/plotter"
 )
 
-func main() {
+// commands maps each subcommand to the function that runs it with the
+// remaining arguments
+var commands = map[string]func(args []string){
+	"fit":      runFit,
+	"predict":  runPredict,
+	"describe": runDescribe,
+	"plot":     runPlot,
+	"cv":       runCV,
+	"path":     runPath,
+	"tune":     runTune,
+}
 
-	// build flags
-	iterationsVisible := flag.Bool("v", true, "make each iteration of the newtons method process visible in stdout")
-	inputFile := flag.String("i", "regression_test.csv", "input path to csv data source where only the first two columns are read")
-	outputFile := flag.String("o", "defaults to name of input data", "the name of the file that the line is outputted to. Must be *.png,*jpeg. *bmp")
-	columns := flag.String("c", "0,1", "specify the columns that you want to read in from the csv in the format: row,col ")
-	describe := flag.Bool("d", false, "output the first five elements of the data used and along with header information")
-	epsilon := flag.Float64("e", .001, "define the value of epsilon")
-	flag.Parse()
+// usage is printed for -h and unknown commands
+const usage = `usage: linreg <command> [flags]
 
-	// by default assign output file to the
-	if *outputFile == "defaults to name of input data" {
-		tempVal := (*inputFile)[:len(*inputFile)-4] + ".png"
-		*outputFile = tempVal
-	}
+commands:
+  fit       fit a regression and print its summary (the default when the
+            first argument is a flag)
+  predict   score a csv with a model saved by fit -save
+  describe  print the first rows and summary statistics of the columns
+  plot      fit a regression and write its plots without printing a summary
+  cv        estimate the out of sample error with k-fold, leave-one-out or
+            holdout validation
+  tune      rank settings of the solver and feature flags by cross-validation
+            and refit the best
+  path      plot the coefficients of a penalized regression across a grid
+            of alphas
 
-	// parse columns of interest
-	xcol, ycol, err := parseColumns(*columns)
-	if err != nil {
-		log.Fatal(err)
-	}
+Run linreg <command> -h for the flags of each command.
+`
 
-	// open data file
-	f, err := os.Open(*inputFile)
-	if err != nil {
-		log.Fatal(err)
+func main() {
+	// running without a command, or with flags only, fits as the tool always
+	// has
+	if len(os.Args) < 2 || strings.HasPrefix(os.Args[1], "-") && os.Args[1] != "-h" && os.Args[1] != "-help" {
+		runFit(os.Args[1:])
+		return
 	}
-	defer f.Close()
-
-	// read in values from csv ready
-	reader := csv.NewReader(f) //*csv.Reader
-	X, Y, head := readInValues(xcol, ycol, reader)
-	if *describe {
-		fmt.Println("\n" + describeString(X, Y, head))
+	run, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprint(os.Stderr, usage)
+		if os.Args[1] == "-h" || os.Args[1] == "-help" || os.Args[1] == "help" {
+			return
+		}
+		log.Fatalf("unknown command %q", os.Args[1])
 	}
+	run(os.Args[2:])
+}
 
-	fmt.Println("Starting Regression")
-	m, b := newtonsRegression(X, Y, *epsilon, *iterationsVisible)
-	r := correlationCoefficient(X, Y)
+// plotFit plots the observations along with the regression equation to fname.
+// With a polynomial basis X holds the single predictor before expansion, and
+// head names the features the coefficients belong to followed by the response.
+// Points are coloured by their weight when weights is not nil and outliers are
+// marked apart from the inliers when inliers is not nil
+func plotFit(X [][]float64, Y []float64, coef []float64, b float64, head []string, summary FitSummary, basis *polyBasis, weights []float64, inliers []bool, fname string) {
+	// make XY pairs for original data. With a single predictor the fitted
+	// curve is drawn against x, otherwise the observations are plotted against
+	// their predictions with the line they would fall on if the fit was exact
+	pts := make(plotter.XYs, len(X))
+	xLabel, yLabel := head[0], head[len(head)-1]
+	features := func(x float64) []float64 { return []float64{x} }
+	if basis != nil {
+		features = basis.expand
+		xLabel = basis.Column
+	}
+	single := basis != nil || len(coef) == 1
+	for i := range X {
+		pts[i].X = X[i][0]
+		pts[i].Y = Y[i]
+		if !single {
+			pts[i].X = predict(coef, X[i], b)
+		}
+	}
+	fit := curve{Label: "fit", F: func(x float64) float64 { return predict(coef, features(x), b) }}
+	if !single {
+		xLabel = "Predicted " + yLabel
+		fit.F = func(x float64) float64 { return x }
+	}
+	// shade the confidence and prediction intervals along the fitted curve
+	// when there is a single predictor to draw it over
+	var bands []band
+	if single && summary.Covariance != nil {
+		bands = intervalBands(X, coef, b, features, summary, summary.Level)
+	}
+	plotRegression(pts, weights, inliers, []curve{fit}, bands, xLabel, yLabel, fname)
+}
 
-	fmt.Printf(
-		"\nRegression Line: y = %.8fx + %.8f\n"+
-			"Correlation Coefficient: %.8f\n"+
-			"MAE: %.8f\n\n",
-		m, b, r, calcMAE(X, Y, m, b),
-	)
+// plotLogistic plots the 0/1 observations, jittered up and down so they do not
+// pile on top of each other, along with the fitted probability to fname. With a
+// single predictor the sigmoid is drawn against x, otherwise against the log
+// odds of each row. The jitter is drawn from seed so the plot is reproducible
+func plotLogistic(X [][]float64, Y []float64, coef []float64, b float64, head []string, basis *polyBasis, seed int64, fname string) {
+	const jitter = 0.05
+	rng := rand.New(rand.NewSource(seed))
+	pts := make(plotter.XYs, len(X))
+	xLabel, yLabel := head[0], head[len(head)-1]+" (jittered)"
+	features := func(x float64) []float64 { return []float64{x} }
+	if basis != nil {
+		features = basis.expand
+		xLabel = basis.Column
+	}
+	single := basis != nil || len(coef) == 1
+	for i := range X {
+		pts[i].X = X[i][0]
+		if !single {
+			pts[i].X = predict(coef, X[i], b)
+		}
+		pts[i].Y = Y[i] + jitter*(2*rng.Float64()-1)
+	}
+	fit := curve{Label: "fit", F: func(x float64) float64 { return sigmoid(predict(coef, features(x), b)) }}
+	if !single {
+		xLabel = "Log Odds"
+		fit.F = sigmoid
+	}
+	plotRegression(pts, nil, nil, []curve{fit}, nil, xLabel, yLabel, fname)
+}
 
-	// make XY pairs for original data as well data points created from the
-	// regression line equation
+// plotQuantiles plots the observations along with the curve of each fitted
+// quantile to fname. The curves are drawn against the single predictor, which
+// X holds before any polynomial expansion
+func plotQuantiles(X [][]float64, Y []float64, fits []quantileFit, head []string, basis *polyBasis, fname string) {
+	if basis == nil && len(fits[0].Coef) != 1 {
+		log.Fatal("plotting several quantiles needs a single predictor, use -plot=false")
+	}
 	pts := make(plotter.XYs, len(X))
-	ptsPred := make(plotter.XYs, len(X))
 	for i := range X {
-		pts[i].X = X[i]
-		pts[i].Y = Y[i]
-		ptsPred[i].X = X[i]
-		ptsPred[i].Y = m*X[i] + b
+		pts[i].X, pts[i].Y = X[i][0], Y[i]
+	}
+	xLabel := head[0]
+	features := func(x float64) []float64 { return []float64{x} }
+	if basis != nil {
+		features = basis.expand
+		xLabel = basis.Column
+	}
+	curves := make([]curve, len(fits))
+	for i, fit := range fits {
+		coef, b := fit.Coef, fit.Intercept
+		curves[i] = curve{Label: fmt.Sprintf("quantile %g", fit.Tau), F: func(x float64) float64 { return predict(coef, features(x), b) }}
 	}
-	plotRegression(pts, ptsPred, *outputFile)
+	plotRegression(pts, nil, nil, curves, nil, xLabel, head[len(head)-1], fname)
 }
 
-// calcMAE calculates the mean absolute error given data points and slope
-// intercept values
-func calcMAE(X, Y []float64, m, b float64) (mAE float64) {
+// calcMAE calculates the mean absolute error given observed values and their
+// predictions
+func calcMAE(Y, yPred []float64) (mAE float64) {
 	for i := range Y {
-		mAE += math.Abs(Y[i]-(m*X[i]+b)) / float64(len(Y))
+		mAE += math.Abs(Y[i]-yPred[i]) / float64(len(Y))
 	}
 	return
 }
 
 // predict will predict the y value for the associated x values
-func predict(m, x, b float64) float64 {
-	return m*x + b
+func predict(coef, x []float64, b float64) float64 {
+	return dot(coef, x) + b
+}
+
+// equationString formats the regression equation using the column names in
+// head where the last name is the response
+func equationString(coef []float64, b float64, head []string) string {
+	if len(coef) == 1 {
+		return fmt.Sprintf("y = %.8fx + %.8f", coef[0], b)
+	}
+	eq := head[len(head)-1] + " ="
+	for j, m := range coef {
+		eq += fmt.Sprintf(" %.8f*%s +", m, head[j])
+	}
+	return eq + fmt.Sprintf(" %.8f", b)
+}
+
+// fatalSolverError logs err along with a hint on how to avoid it when it came
+// from a solver and exits with a non-zero status
+func fatalSolverError(err error) {
+	if serr, ok := err.(*SolverError); ok && serr.Hint() != "" {
+		log.Fatalf("%v\n%s", err, serr.Hint())
+	}
+	log.Fatal(err)
+}
+
+// fatalFitError is fatalSolverError for a fit of the features expanded by
+// basis, which is nil without -degree. An ill-conditioned fit of a raw
+// polynomial basis is put down to its powers of x, which are nearly collinear,
+// rather than to the data
+func fatalFitError(err error, basis *polyBasis) {
+	if serr, ok := err.(*SolverError); ok && basis != nil && !basis.Orthogonal && basis.Degree > 1 {
+		if serr.Err == ErrSingularHessian || serr.Err == ErrRankDeficient {
+			log.Fatalf("%v\nthe powers of a raw polynomial basis are nearly collinear, use -basis orthogonal or -solver qr", err)
+		}
+	}
+	fatalSolverError(err)
+}
+
+// intervalBands samples the prediction and confidence intervals of a single
+// predictor model across the range of its x values, where features maps x to
+// the features the coefficients belong to
+func intervalBands(X [][]float64, coef []float64, b float64, features func(float64) []float64, summary FitSummary, level float64) []band {
+	const samples = 100
+	xs := column(X, 0)
+	xmin, xmax := xs[0], xs[0]
+	for _, x := range xs {
+		xmin, xmax = math.Min(xmin, x), math.Max(xmax, x)
+	}
+	label := fmt.Sprintf("%g%% ", 100*level)
+	pred := band{Label: label + "prediction", Color: color.Gray{Y: 225}}
+	conf := band{Label: label + "confidence", Color: color.Gray{Y: 170}}
+	sigma2 := summary.ResidualSE * summary.ResidualSE
+	tCrit := studentTQuantile(1-(1-level)/2, float64(summary.DFResid))
+	for i := 0; i < samples; i++ {
+		x := xmin + (xmax-xmin)*float64(i)/(samples-1)
+		_, c, p := predictIntervals(coef, features(x), b, summary.Covariance, sigma2, tCrit)
+		pred.Lower = append(pred.Lower, plotter.XY{X: x, Y: p.Lower})
+		pred.Upper = append(pred.Upper, plotter.XY{X: x, Y: p.Upper})
+		conf.Lower = append(conf.Lower, plotter.XY{X: x, Y: c.Lower})
+		conf.Upper = append(conf.Upper, plotter.XY{X: x, Y: c.Upper})
+	}
+	return []band{pred, conf}
 }
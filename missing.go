@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// missingPolicy is how a column treats values that are missing or cannot be
+// parsed as numbers
+type missingPolicy int
+
+const (
+	// dropMissing skips the whole row
+	dropMissing missingPolicy = iota
+	// failMissing stops reading with an error
+	failMissing
+	// imputeMean replaces the value with the mean of the column
+	imputeMean
+	// imputeMedian replaces the value with the median of the column
+	imputeMedian
+	// imputeConstant replaces the value with a fixed constant
+	imputeConstant
+)
+
+// missingPolicyNames maps the names accepted on the command line to policies
+var missingPolicyNames = map[string]missingPolicy{
+	"drop":            dropMissing,
+	"fail":            failMissing,
+	"impute-mean":     imputeMean,
+	"impute-median":   imputeMedian,
+	"impute-constant": imputeConstant,
+}
+
+// String returns the command line name of the policy
+func (p missingPolicy) String() string {
+	for name, policy := range missingPolicyNames {
+		if policy == p {
+			return name
+		}
+	}
+	return fmt.Sprintf("missingPolicy(%d)", int(p))
+}
+
+// naTokens are the field values, compared case insensitively after trimming
+// space, that mark a value as missing
+var naTokens = map[string]bool{
+	"":     true,
+	"na":   true,
+	"n/a":  true,
+	"nan":  true,
+	"null": true,
+	"none": true,
+}
+
+// isNA reports whether the field is one of the tokens used for missing values
+func isNA(field string) bool {
+	return naTokens[strings.ToLower(strings.TrimSpace(field))]
+}
+
+// missingSpec is the parsed -missing flag, a default policy along with
+// overrides for columns given by header name or index
+type missingSpec struct {
+	Default  missingPolicy
+	Columns  map[string]missingPolicy
+	Constant float64
+}
+
+// parseMissing parses a comma separated list of entries that are either a
+// policy, which becomes the default, or column=policy. Constant is the value
+// used by the impute-constant policy
+func parseMissing(s string, constant float64) (missingSpec, error) {
+	spec := missingSpec{Default: dropMissing, Columns: map[string]missingPolicy{}, Constant: constant}
+	if strings.TrimSpace(s) == "" {
+		return spec, nil
+	}
+	names := make([]string, 0, len(missingPolicyNames))
+	for name := range missingPolicyNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, entry := range strings.Split(s, ",") {
+		col, name := "", strings.TrimSpace(entry)
+		if i := strings.Index(name, "="); i >= 0 {
+			col, name = strings.TrimSpace(name[:i]), strings.TrimSpace(name[i+1:])
+		}
+		policy, ok := missingPolicyNames[name]
+		if !ok {
+			return spec, fmt.Errorf("unknown missing value policy %q, must be one of %s", name, strings.Join(names, ", "))
+		}
+		if col == "" {
+			spec.Default = policy
+		} else {
+			spec.Columns[col] = policy
+		}
+	}
+	return spec, nil
+}
+
+// policies returns the policy of each column read, where names and cols hold
+// the name and index of each column. An error is returned if the spec names a
+// column that is not read
+func (spec missingSpec) policies(names []string, cols []int) ([]missingPolicy, error) {
+	used := map[string]bool{}
+	policies := make([]missingPolicy, len(cols))
+	for i := range cols {
+		policies[i] = spec.Default
+		for _, key := range []string{names[i], strconv.Itoa(cols[i])} {
+			if p, ok := spec.Columns[key]; ok {
+				policies[i] = p
+				used[key] = true
+			}
+		}
+	}
+	for key := range spec.Columns {
+		if !used[key] {
+			return nil, fmt.Errorf("missing value policy given for %q which is not one of the columns read: %s", key, strings.Join(names, ", "))
+		}
+	}
+	return policies, nil
+}
+
+// Reasons a row is dropped while reading
+const (
+	reasonMissing = "missing"
+	reasonInvalid = "invalid"
+	reasonShort   = "short-row"
+)
+
+// DataQuality reports how many rows were read from a csv and what happened to
+// the values that were missing or could not be parsed
+type DataQuality struct {
+	RowsRead        int             `json:"rows_read"`
+	RowsUsed        int             `json:"rows_used"`
+	RowsDropped     int             `json:"rows_dropped"`
+	DroppedByReason map[string]int  `json:"dropped_by_reason"`
+	Columns         []ColumnQuality `json:"columns"`
+}
+
+// ColumnQuality counts the problem values of a single column
+type ColumnQuality struct {
+	Name   string `json:"name"`
+	Policy string `json:"policy"`
+	// Missing counts values that were empty or one of the NA tokens, Invalid
+	// those that were present but not numeric
+	Missing int `json:"missing"`
+	Invalid int `json:"invalid"`
+	Imputed int `json:"imputed"`
+}
+
+// writeText writes a human readable report to w
+func (q DataQuality) writeText(w io.Writer) {
+	fmt.Fprintf(w, "Data quality: %d rows read, %d used, %d dropped\n", q.RowsRead, q.RowsUsed, q.RowsDropped)
+	reasons := make([]string, 0, len(q.DroppedByReason))
+	for reason := range q.DroppedByReason {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Fprintf(w, "  dropped %-10s %d\n", reason+":", q.DroppedByReason[reason])
+	}
+	for _, c := range q.Columns {
+		fmt.Fprintf(w, "  column %-12s policy %-15s missing %d\tinvalid %d\timputed %d\n",
+			c.Name, c.Policy, c.Missing, c.Invalid, c.Imputed)
+	}
+}
+
+// writeJSON writes the report to w as a json document
+func (q DataQuality) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(q)
+}
+
+// imputeValue returns the value that replaces missing entries of a column
+// under the policy, computed from the observed values of the column
+func imputeValue(policy missingPolicy, observed []float64, constant float64) float64 {
+	switch policy {
+	case imputeMean:
+		var mean float64
+		for _, v := range observed {
+			mean += v / float64(len(observed))
+		}
+		return mean
+	case imputeMedian:
+		return median(observed)
+	case imputeConstant:
+		return constant
+	}
+	return math.NaN()
+}
+
+// median returns the median of the values without modifying them
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
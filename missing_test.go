@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/csv"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIsHeader(t *testing.T) {
+	tests := []struct {
+		record []string
+		want   bool
+	}{
+		{[]string{"x", "y"}, true},
+		{[]string{"1", "y"}, true},
+		{[]string{"1", "2.5"}, false},
+		{[]string{" 1 ", "-3e2"}, false},
+		{[]string{"NA", "3"}, false},
+		{[]string{"", "null", " None ", "n/a", "NaN"}, false},
+	}
+	for _, tt := range tests {
+		if got := isHeader(tt.record, nil); got != tt.want {
+			t.Errorf("isHeader(%q) = %v, want %v", tt.record, got, tt.want)
+		}
+	}
+	// only the columns that are read decide
+	record := []string{"2020-01-03", "1", "2.5", "id7"}
+	if isHeader(record, []int{1, 2}) {
+		t.Errorf("isHeader(%q) of columns 1 and 2 is a header", record)
+	}
+	if !isHeader(record, []int{2, 3}) {
+		t.Errorf("isHeader(%q) of columns 2 and 3 is not a header", record)
+	}
+}
+
+func TestParseMissing(t *testing.T) {
+	tests := []struct {
+		s    string
+		want missingSpec
+		ok   bool
+	}{
+		{"", missingSpec{Default: dropMissing, Columns: map[string]missingPolicy{}}, true},
+		{"fail", missingSpec{Default: failMissing, Columns: map[string]missingPolicy{}}, true},
+		{"impute-mean, TV=drop, 2=impute-constant", missingSpec{
+			Default: imputeMean,
+			Columns: map[string]missingPolicy{"TV": dropMissing, "2": imputeConstant},
+		}, true},
+		{"Sales=impute-median", missingSpec{Default: dropMissing, Columns: map[string]missingPolicy{"Sales": imputeMedian}}, true},
+		{"impute", missingSpec{}, false},
+		{"TV=skip", missingSpec{}, false},
+	}
+	for _, tt := range tests {
+		got, err := parseMissing(tt.s, 0)
+		if (err == nil) != tt.ok {
+			t.Errorf("parseMissing(%q) error %v, want ok %v", tt.s, err, tt.ok)
+			continue
+		}
+		if tt.ok && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseMissing(%q) = %+v, want %+v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestMissingPolicies(t *testing.T) {
+	names, cols := []string{"TV", "Radio", "Sales"}, []int{1, 2, 4}
+	spec, err := parseMissing("impute-mean,Radio=fail,4=impute-median", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := spec.policies(names, cols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Radio is named and Sales is given by its index in the csv
+	if want := []missingPolicy{imputeMean, failMissing, imputeMedian}; !reflect.DeepEqual(got, want) {
+		t.Errorf("policies = %v, want %v", got, want)
+	}
+	for _, s := range []string{"Newspaper=drop", "0=drop"} {
+		spec, err := parseMissing(s, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := spec.policies(names, cols); err == nil {
+			t.Errorf("%s: a policy for a column that is not read was accepted", s)
+		}
+	}
+}
+
+func TestImputeValue(t *testing.T) {
+	observed := []float64{4, 1, 10, 3}
+	tests := []struct {
+		policy missingPolicy
+		want   float64
+	}{
+		{imputeMean, 4.5},
+		{imputeMedian, 3.5},
+		{imputeConstant, -1},
+	}
+	for _, tt := range tests {
+		if got := imputeValue(tt.policy, observed, -1); got != tt.want {
+			t.Errorf("imputeValue(%v) = %g, want %g", tt.policy, got, tt.want)
+		}
+	}
+	if got := median([]float64{5, 1, 3}); got != 3 {
+		t.Errorf("median of an odd count = %g, want 3", got)
+	}
+	if got := imputeValue(dropMissing, observed, 0); !math.IsNaN(got) {
+		t.Errorf("imputeValue(drop) = %g, want NaN", got)
+	}
+}
+
+func TestReadInValuesMissing(t *testing.T) {
+	const data = `x1,x2,y
+1,10,2
+NA,20,4
+3,,6
+4,40,bad
+5,50
+6,null,12
+`
+	tests := []struct {
+		missing string
+		X       [][]float64
+		Y       []float64
+		quality DataQuality
+	}{
+		{
+			"drop",
+			[][]float64{{1, 10}},
+			[]float64{2},
+			DataQuality{RowsRead: 6, RowsUsed: 1, RowsDropped: 5,
+				DroppedByReason: map[string]int{reasonMissing: 3, reasonInvalid: 1, reasonShort: 1},
+				Columns: []ColumnQuality{
+					{Name: "x1", Policy: "drop", Missing: 1},
+					{Name: "x2", Policy: "drop", Missing: 2},
+					{Name: "y", Policy: "drop", Missing: 1, Invalid: 1},
+				}},
+		},
+		{
+			// the x1 gap takes the mean and the x2 gaps the median of the
+			// values observed in the rows kept once y drops its rows
+			"impute-mean,x2=impute-median,y=drop",
+			[][]float64{{1, 10}, {10.0 / 3, 20}, {3, 15}, {6, 15}},
+			[]float64{2, 4, 6, 12},
+			DataQuality{RowsRead: 6, RowsUsed: 4, RowsDropped: 2,
+				DroppedByReason: map[string]int{reasonInvalid: 1, reasonShort: 1},
+				Columns: []ColumnQuality{
+					{Name: "x1", Policy: "impute-mean", Missing: 1, Imputed: 1},
+					{Name: "x2", Policy: "impute-median", Missing: 2, Imputed: 2},
+					{Name: "y", Policy: "drop", Missing: 1, Invalid: 1},
+				}},
+		},
+	}
+	for _, tt := range tests {
+		spec, err := parseMissing(tt.missing, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		X, Y, W, head, quality, err := readInValues([]string{"x1", "x2"}, "y", "", spec, csv.NewReader(strings.NewReader(data)))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.missing, err)
+		}
+		if W != nil || !reflect.DeepEqual(head, []string{"x1", "x2", "y"}) {
+			t.Errorf("%s: weights %v and head %q", tt.missing, W, head)
+		}
+		if len(X) != len(tt.X) {
+			t.Fatalf("%s: read %d rows, want %d", tt.missing, len(X), len(tt.X))
+		}
+		for i := range tt.X {
+			for j := range tt.X[i] {
+				if math.Abs(X[i][j]-tt.X[i][j]) > 1e-12 {
+					t.Errorf("%s: row %d is %v, want %v", tt.missing, i, X[i], tt.X[i])
+					break
+				}
+			}
+		}
+		if !reflect.DeepEqual(Y, tt.Y) {
+			t.Errorf("%s: responses %v, want %v", tt.missing, Y, tt.Y)
+		}
+		if !reflect.DeepEqual(quality, tt.quality) {
+			t.Errorf("%s: quality %+v, want %+v", tt.missing, quality, tt.quality)
+		}
+	}
+
+	spec, _ := parseMissing("fail", 0)
+	if _, _, _, _, _, err := readInValues([]string{"x1", "x2"}, "y", "", spec, csv.NewReader(strings.NewReader(data))); err == nil {
+		t.Error("the fail policy read a csv with missing values")
+	}
+}
+
+func TestReadInValuesHeaderlessNA(t *testing.T) {
+	// a first row with a missing value is data, not a header
+	const data = "NA,3\n2,5\n3,7\n4,9\n"
+	spec, _ := parseMissing("drop", 0)
+	X, Y, _, head, quality, err := readInValues([]string{"0"}, "1", "", spec, csv.NewReader(strings.NewReader(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quality.RowsRead != 4 || quality.RowsDropped != 1 || len(X) != 3 {
+		t.Errorf("read %d rows, dropped %d and kept %d, want 4, 1 and 3", quality.RowsRead, quality.RowsDropped, len(X))
+	}
+	if want := []string{"col0", "col1"}; !reflect.DeepEqual(head, want) {
+		t.Errorf("head %q, want %q", head, want)
+	}
+	if want := []float64{5, 7, 9}; !reflect.DeepEqual(Y, want) {
+		t.Errorf("responses %v, want %v", Y, want)
+	}
+}
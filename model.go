@@ -0,0 +1,219 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// modelVersion is the version of the model file layout written by saveModel
+const modelVersion = 1
+
+// Model is a fitted linear model as saved to and loaded from a json file
+type Model struct {
+	Version   int       `json:"version"`
+	Features  []string  `json:"features"`
+	Response  string    `json:"response"`
+	Coef      []float64 `json:"coefficients"`
+	Intercept float64   `json:"intercept"`
+	Solver    string    `json:"solver"`
+	// Summary holds the fit statistics, including the covariance of the
+	// parameters used for prediction intervals
+	Summary FitSummary `json:"summary"`
+	// DataHash is the hex encoded sha256 of the training csv
+	DataHash string `json:"training_data_sha256"`
+	// Basis expands the single predictor column into the features when the
+	// model is polynomial
+	Basis *polyBasis `json:"polynomial,omitempty"`
+	// Family is binomial for a logistic regression, whose predictions are
+	// the probability of a 1, and empty for a linear model
+	Family string `json:"family,omitempty"`
+}
+
+// newModel returns the model with coefficients coef and intercept b, where head
+// holds the name of each feature followed by the response
+func newModel(coef []float64, b float64, head []string, solver string, summary FitSummary, dataHash hash.Hash) *Model {
+	return &Model{
+		Version:   modelVersion,
+		Features:  append([]string{}, head[:len(coef)]...),
+		Response:  head[len(coef)],
+		Coef:      coef,
+		Intercept: b,
+		Solver:    solver,
+		Summary:   summary,
+		DataHash:  hex.EncodeToString(dataHash.Sum(nil)),
+	}
+}
+
+// saveModel writes the model to fname as json
+func saveModel(m *Model, fname string) error {
+	f, err := os.Create(fname)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// loadModel reads a model written by saveModel from fname
+func loadModel(fname string) (*Model, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var m Model
+	if err := json.NewDecoder(f).Decode(&m); err != nil {
+		return nil, fmt.Errorf("%s: %v", fname, err)
+	}
+	if m.Version != modelVersion {
+		return nil, fmt.Errorf("%s: model file version %d is not supported, expected %d", fname, m.Version, modelVersion)
+	}
+	if len(m.Features) != len(m.Coef) {
+		return nil, fmt.Errorf("%s: model has %d features but %d coefficients", fname, len(m.Features), len(m.Coef))
+	}
+	return &m, nil
+}
+
+// newDataHash returns the hash used to fingerprint training data
+func newDataHash() hash.Hash {
+	return sha256.New()
+}
+
+// scoreCSV copies every record of r to w with the prediction of the model, and
+// the interval bounds unless interval is none, appended. A binomial model
+// predicts the probability of a 1, with the interval of the log odds mapped
+// onto probabilities. Rows whose features cannot be parsed get empty cells and
+// are counted in the returned total
+func scoreCSV(m *Model, r *csv.Reader, w *csv.Writer, interval string, level float64) (int, error) {
+	r.FieldsPerRecord = -1
+	first, err := r.Read()
+	if err == io.EOF {
+		return 0, fmt.Errorf("csv is empty")
+	}
+	if err != nil {
+		return 0, err
+	}
+	// features are found by name in the header, or by the index in names
+	// like col3 that fit gives the columns of csv files without one. A
+	// polynomial model reads its single predictor and expands it
+	columns := m.Features
+	if m.Basis != nil {
+		columns = []string{m.Basis.Column}
+	}
+	indices := make([]string, len(columns))
+	for j, name := range columns {
+		indices[j] = strings.TrimPrefix(name, "col")
+	}
+	var header []string
+	if isHeader(first, indexColumns(indices)) {
+		header = first
+	}
+	cols := make([]int, len(columns))
+	for j, name := range columns {
+		cols[j] = -1
+		for i, h := range header {
+			if strings.TrimSpace(h) == name {
+				cols[j] = i
+			}
+		}
+		if cols[j] < 0 && strings.HasPrefix(name, "col") {
+			if i, err := strconv.Atoi(name[len("col"):]); err == nil && header == nil {
+				cols[j] = i
+			}
+		}
+		if cols[j] < 0 {
+			return 0, fmt.Errorf("feature %q of the model is not a column of the csv", name)
+		}
+	}
+	pred := "predicted_" + m.Response
+	if header != nil {
+		extra := []string{pred}
+		if interval != "none" {
+			extra = append(extra, pred+"_lower", pred+"_upper")
+		}
+		if err := w.Write(append(header, extra...)); err != nil {
+			return 0, err
+		}
+	}
+
+	sigma2 := m.Summary.ResidualSE * m.Summary.ResidualSE
+	crit := studentTQuantile(1-(1-level)/2, float64(m.Summary.DFResid))
+	// inverseLink maps the linear predictor onto the scale of the response
+	inverseLink := func(eta float64) float64 { return eta }
+	if m.Family == "binomial" {
+		// the log odds have wald intervals, as their coefficients do
+		inverseLink, crit = sigmoid, normalQuantile(1-(1-level)/2)
+	}
+	skipped := 0
+	record := first
+	if header != nil {
+		record = nil
+	}
+	for {
+		if record == nil {
+			if record, err = r.Read(); err == io.EOF {
+				break
+			}
+			if err != nil {
+				return skipped, err
+			}
+		}
+		x := make([]float64, len(cols))
+		ok := true
+		for j, col := range cols {
+			if col >= len(record) || isNA(record[col]) {
+				ok = false
+				break
+			}
+			if x[j], err = strconv.ParseFloat(strings.TrimSpace(record[col]), 64); err != nil {
+				ok = false
+				break
+			}
+		}
+		if ok && m.Basis != nil {
+			x = m.Basis.expand(x[0])
+		}
+		var extra []string
+		switch {
+		case !ok:
+			skipped++
+			extra = []string{""}
+			if interval != "none" {
+				extra = append(extra, "", "")
+			}
+		case interval == "none":
+			extra = []string{formatFloat(inverseLink(predict(m.Coef, x, m.Intercept)))}
+		default:
+			yhat, conf, obs := predictIntervals(m.Coef, x, m.Intercept, m.Summary.Covariance, sigma2, crit)
+			bounds := obs
+			if interval == "confidence" {
+				bounds = conf
+			}
+			extra = []string{formatFloat(inverseLink(yhat)), formatFloat(inverseLink(bounds.Lower)), formatFloat(inverseLink(bounds.Upper))}
+		}
+		if err := w.Write(append(record, extra...)); err != nil {
+			return skipped, err
+		}
+		record = nil
+	}
+	return skipped, nil
+}
+
+// formatFloat formats a value for csv output with the fewest digits that read
+// back to the same value
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fitTestModel fits the advertising data with the qr solver and returns the
+// model as fit -save would write it
+func fitTestModel(t *testing.T) *Model {
+	t.Helper()
+	X, Y := readTestCSV(t, "advertising.csv", []string{"TV", "Radio"}, "Sales")
+	result, err := qrSolver{}.Fit(X, Y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head := []string{"TV", "Radio", "Sales"}
+	summary := newFitSummary(X, Y, nil, result.Coef, result.Intercept, head, .95)
+	h := newDataHash()
+	h.Write([]byte("advertising"))
+	return newModel(result.Coef, result.Intercept, head, "qr", summary, h)
+}
+
+func TestModelRoundTrip(t *testing.T) {
+	m := fitTestModel(t)
+	fname := filepath.Join(t.TempDir(), "model.json")
+	if err := saveModel(m, fname); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadModel(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Coef, m.Coef) || loaded.Intercept != m.Intercept || loaded.DataHash != m.DataHash {
+		t.Errorf("loaded %v + %v (%s), saved %v + %v (%s)", loaded.Coef, loaded.Intercept, loaded.DataHash, m.Coef, m.Intercept, m.DataHash)
+	}
+	if !reflect.DeepEqual(loaded.Features, []string{"TV", "Radio"}) || loaded.Response != "Sales" {
+		t.Errorf("loaded features %q and response %q", loaded.Features, loaded.Response)
+	}
+	if !reflect.DeepEqual(loaded.Summary.Covariance, m.Summary.Covariance) {
+		t.Error("the covariance did not survive the round trip")
+	}
+
+	// the columns are in a different order than in training, the third row
+	// has a missing feature and the fourth a feature that is not a number
+	const data = `Radio,id,TV
+37.8,a,230.1
+39.3,b,44.5
+NA,c,17.2
+41.3,d,x
+10.8,e,151.5
+`
+	rows := [][]float64{{230.1, 37.8}, {44.5, 39.3}, nil, nil, {151.5, 10.8}}
+	var out bytes.Buffer
+	w := csv.NewWriter(&out)
+	skipped, err := scoreCSV(loaded, csv.NewReader(strings.NewReader(data)), w, "prediction", .95)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	if skipped != 2 {
+		t.Errorf("skipped %d rows, want 2", skipped)
+	}
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Radio", "id", "TV", "predicted_Sales", "predicted_Sales_lower", "predicted_Sales_upper"}; !reflect.DeepEqual(records[0], want) {
+		t.Errorf("header %q, want %q", records[0], want)
+	}
+	sigma2 := m.Summary.ResidualSE * m.Summary.ResidualSE
+	crit := studentTQuantile(.975, float64(m.Summary.DFResid))
+	for i, x := range rows {
+		record := records[i+1]
+		if x == nil {
+			if want := []string{"", "", ""}; !reflect.DeepEqual(record[3:], want) {
+				t.Errorf("row %d with an unusable feature was scored %q", i, record[3:])
+			}
+			continue
+		}
+		yhat, _, obs := predictIntervals(m.Coef, x, m.Intercept, m.Summary.Covariance, sigma2, crit)
+		if yhat != predict(m.Coef, x, m.Intercept) {
+			t.Fatalf("row %d: interval centre %g is not the prediction", i, yhat)
+		}
+		for j, want := range []float64{yhat, obs.Lower, obs.Upper} {
+			got, err := strconv.ParseFloat(record[3+j], 64)
+			if err != nil || got != want {
+				t.Errorf("row %d column %d is %q, want %g", i, j, record[3+j], want)
+			}
+		}
+	}
+
+	// a csv that lacks one of the features cannot be scored
+	w = csv.NewWriter(&bytes.Buffer{})
+	if _, err := scoreCSV(loaded, csv.NewReader(strings.NewReader("TV,Sales\n1,2\n")), w, "none", .95); err == nil || !strings.Contains(err.Error(), `"Radio"`) {
+		t.Errorf("scoring without the Radio column returned %v", err)
+	}
+}
+
+func TestScoreHeaderlessText(t *testing.T) {
+	// a model fitted to a headerless csv names its columns by index, and a
+	// text column that the model does not read leaves the first row as data
+	m := fitTestModel(t)
+	m.Features, m.Response = []string{"col1", "col2"}, "col3"
+	const data = "a,230.1,37.8\nb,44.5,39.3\nc,17.2,45.9\n"
+	var out bytes.Buffer
+	w := csv.NewWriter(&out)
+	if _, err := scoreCSV(m, csv.NewReader(strings.NewReader(data)), w, "none", .95); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("scored %d rows, want 3", len(records))
+	}
+	want := formatFloat(predict(m.Coef, []float64{230.1, 37.8}, m.Intercept))
+	if records[0][0] != "a" || records[0][3] != want {
+		t.Errorf("first row scored as %q, want the prediction %s", records[0], want)
+	}
+}
+
+func TestLoadModelVersion(t *testing.T) {
+	m := fitTestModel(t)
+	m.Version = modelVersion + 1
+	fname := filepath.Join(t.TempDir(), "model.json")
+	if err := saveModel(m, fname); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadModel(fname); err == nil {
+		t.Errorf("a model of version %d was loaded", m.Version)
+	}
+}
@@ -26,53 +26,190 @@ func correlationCoefficient(X, Y []float64) float64 {
 		math.Pow((n*sumX2-math.Pow(sumX, 2))*(n*sumY2-math.Pow(sumY, 2)), .5)
 }
 
-// newtonsRegression will use netwons method to computer linear regression on a
-// two dimensional vectorspace
-func newtonsRegression(X, Y []float64, epsilon float64, show bool) (float64, float64) {
-	// define m and b as well as their changes, the magnitude of those changes,
-	// and the number of iterations counted
-	var m, b, deltaM, deltaB, heshMagnitude, iterations float64
+// newtonsRegression will use netwons method to compute a multiple linear
+// regression of Y on the columns of X, weighting each row by W unless it is
+// nil. The coefficient of each column is returned along with the intercept and
+// the trace of every iteration
+func newtonsRegression(X [][]float64, Y, W []float64, epsilon float64, maxIter int, ls lineSearch) ([]float64, float64, []Iteration, error) {
+	if err := checkFinite(X, Y); err != nil {
+		return nil, 0, nil, err
+	}
+	theta, trace, err := minimizeNewton(leastSquares{X: X, Y: Y, W: W}, make([]float64, len(X[0])+1), epsilon, maxIter, ls)
+	if err != nil {
+		return nil, 0, trace, err
+	}
+	return theta[1:], theta[0], trace, nil
+}
+
+// minimizeNewton minimizes the objective with netwons method starting from
+// theta, stepping along the newton direction by the length the line search
+// accepts. A *SolverError is returned if the hessian is singular, a step is
+// not finite, the line search fails, or the newton step is still above
+// epsilon after maxIter iterations
+func minimizeNewton(obj objective, theta []float64, epsilon float64, maxIter int, ls lineSearch) ([]float64, []Iteration, error) {
+	theta = append([]float64{}, theta...)
+	// define the magnitude of the newton step and the iterations recorded
+	var stepMagnitude float64
+	var trace []Iteration
 	// loop until magnitude is lower than epsilon except for the first iteration
-	for heshMagnitude > epsilon || iterations == 0 {
-		// calculate changes in m and b as well as calculating their combined magnitude
-		deltaM, deltaB = stepNewton(X, Y, m, b)
-		heshMagnitude = math.Pow((math.Pow(deltaM, 2) + math.Pow(deltaB, 2)), .5)
-		if show {
-			fmt.Printf("Iteration: %.0f\t%cm: %.8f\t%cb: %.8f\t |%cf|: %.8f\tm: %.16f\tb: %.16f\n",
-				iterations, 0x0394, deltaM, 0x0394, deltaB, 0x0394, heshMagnitude, m, b)
+	for stepMagnitude > epsilon || len(trace) == 0 {
+		if len(trace) >= maxIter {
+			return nil, trace, &SolverError{
+				Err:       ErrNotConverged,
+				Iteration: len(trace),
+				Detail:    fmt.Sprintf("step magnitude %.8g is above epsilon %g", stepMagnitude, epsilon),
+			}
+		}
+		// calculate the newton direction as well as its magnitude
+		d, g, err := stepNewton(obj, theta)
+		if err != nil {
+			err.Iteration = len(trace)
+			return nil, trace, err
+		}
+		stepMagnitude = norm(d)
+		if !isFinite(d) {
+			return nil, trace, &SolverError{Err: ErrNonFinite, Iteration: len(trace), Detail: "newton step is not finite"}
+		}
+		// take as much of the step as the line search allows. A step below
+		// epsilon is within round off of the minimum so it is taken in full
+		alpha := 1.0
+		if stepMagnitude > epsilon {
+			var lsErr error
+			alpha, lsErr = ls.search(obj, theta, obj.Value(theta), g, d)
+			if lsErr != nil {
+				return nil, trace, &SolverError{Err: ErrLineSearch, Iteration: len(trace), Detail: lsErr.Error()}
+			}
+		}
+		theta = axpy(alpha, d, theta)
+		trace = append(trace, newIteration(len(trace), stepMagnitude, alpha, obj.Value(theta), theta))
+	}
+	return theta, trace, nil
+}
+
+// stepNewton computes the newton direction of the objective at theta, the step
+// that minimises its local quadratic model, along with the gradient at theta
+func stepNewton(obj objective, theta []float64) ([]float64, []float64, *SolverError) {
+	grad := obj.Gradient(theta)
+	hess := obj.Hessian(theta)
+	k := len(theta)
+	// refuse to solve when the hessian is singular or too ill-conditioned for
+	// the step to be trusted. The hessian is first scaled to a unit diagonal so
+	// that columns measured in small or large units are not mistaken for
+	// degenerate ones
+	eig := symEigenvalues(unitDiagonal(hess))
+	if eig[0] <= 0 || eig[k-1]/eig[0] > maxHessianCondition {
+		return nil, nil, &SolverError{
+			Err:    ErrSingularHessian,
+			Detail: fmt.Sprintf("eigenvalues of the scaled hessian range from %.3g to %.3g", eig[0], eig[k-1]),
 		}
-		//why are these signs different. Wolfe Conditions?
-		m = m + deltaM
-		b = b - deltaB
-		iterations++
 	}
-	return m, b
+	// solve the hessian against the negative gradient for the direction
+	negGrad := make([]float64, k)
+	for j := range grad {
+		negGrad[j] = -grad[j]
+	}
+	d, err := solveLinear(hess, negGrad)
+	if err != nil {
+		return nil, nil, &SolverError{Err: ErrSingularHessian, Detail: err.Error()}
+	}
+	return d, grad, nil
 }
 
-// stepNewton computes a single iterative step of netwons methdod
-func stepNewton(X, Y []float64, m, b float64) (float64, float64) {
-	// dfine all partial derivates
-	var fm, fb, fmm, fbb, fmb float64
-	// sum up change in error with respect to m, b, m^2, and m*b
-	// note that b^2 is not here because it is constant at 2.0
+// maxHessianCondition is the largest ratio between the eigenvalues of the
+// hessian, scaled to a unit diagonal, that stepNewton will accept
+const maxHessianCondition = 1e12
+
+// unitDiagonal returns the symmetric matrix A scaled by the inverse square root
+// of its diagonal on both sides, so every diagonal entry is one. A row with a
+// diagonal that is not positive is left as zeros, leaving the result singular
+func unitDiagonal(A [][]float64) [][]float64 {
+	n := len(A)
+	d := make([]float64, n)
+	for i := range A {
+		if A[i][i] > 0 {
+			d[i] = 1 / math.Sqrt(A[i][i])
+		}
+	}
+	S := make([][]float64, n)
+	for i := range A {
+		S[i] = make([]float64, n)
+		for j := range A[i] {
+			S[i][j] = d[i] * A[i][j] * d[j]
+		}
+	}
+	return S
+}
+
+// qrRegression solves the least squares problem for Y on the columns of X in
+// closed form using a householder QR factorization of the design matrix. Rows
+// are weighted by W unless it is nil, by scaling each row of the design matrix
+// and Y by the square root of its weight. The coefficient of each column is
+// returned along with the intercept and the condition number of the (scaled)
+// design matrix. An error is returned when the design matrix is rank deficient
+func qrRegression(X [][]float64, Y, W []float64) ([]float64, float64, float64, error) {
+	if err := checkFinite(X, Y); err != nil {
+		return nil, 0, 0, err
+	}
+	A := designMatrix(X)
+	if W != nil {
+		Y = append([]float64{}, Y...)
+		for i := range A {
+			sw := math.Sqrt(W[i])
+			for j := range A[i] {
+				A[i][j] *= sw
+			}
+			Y[i] *= sw
+		}
+	}
+	k := len(A[0])
+	if len(A) < k {
+		return nil, 0, 0, fmt.Errorf("qr solver needs at least %d rows but only %d were read", k, len(A))
+	}
+	R, qty := householderQR(A, Y)
+	// a diagonal entry of R that is tiny relative to the largest one means that
+	// the matching column is a linear combination of the columns before it
+	var maxDiag float64
+	for j := 0; j < k; j++ {
+		maxDiag = math.Max(maxDiag, math.Abs(R[j][j]))
+	}
+	for j := 0; j < k; j++ {
+		if math.Abs(R[j][j]) <= 1e-10*maxDiag {
+			if j == 0 {
+				return nil, 0, 0, &SolverError{Err: ErrRankDeficient, Iteration: -1, Detail: "the intercept column is zero"}
+			}
+			return nil, 0, 0, &SolverError{Err: ErrRankDeficient, Iteration: -1, Detail: fmt.Sprintf("predictor %d is constant or a linear combination of the others", j)}
+		}
+	}
+	// back substitute R*theta = Q^T*y
+	theta := make([]float64, k)
+	for i := k - 1; i >= 0; i-- {
+		sum := qty[i]
+		for j := i + 1; j < k; j++ {
+			sum -= R[i][j] * theta[j]
+		}
+		theta[i] = sum / R[i][i]
+	}
+	// the singular values of the design matrix are the square roots of the
+	// eigenvalues of R^T*R
+	RtR := make([][]float64, k)
+	for i := range RtR {
+		RtR[i] = make([]float64, k)
+		for j := range RtR[i] {
+			for l := 0; l <= i && l <= j; l++ {
+				RtR[i][j] += R[l][i] * R[l][j]
+			}
+		}
+	}
+	eig := symEigenvalues(RtR)
+	cond := math.Sqrt(eig[k-1] / eig[0])
+	return theta[1:], theta[0], cond, nil
+}
+
+// designMatrix returns the rows of X with a leading one for the intercept
+func designMatrix(X [][]float64) [][]float64 {
+	A := make([][]float64, len(X))
 	for i := range X {
-		fm += -X[i] * (Y[i] - (m*X[i] + b))
-		fb += -(Y[i] - (m*X[i] + b))
-		fmm += X[i] * X[i]
-		fmb += X[i]
-	}
-	// multiply all partials by the two constant that is almost always
-	// pulled out of the derivative equation and devide by n to normalize
-	// note that the b^2 derivates is always a constant values of 2
-	n := float64(len(X))
-	fm = 2 * fm / n
-	fb = 2 * fb / n
-	fmm = fmm / n
-	fbb = 2.0
-	fmb = 2 * fmb / n
-	// calculate the determinant of the hessian matrix and the change of m and b
-	determ := fmm*fbb - math.Pow(fmb, 2)
-	dm := (fm*fbb + fb*fmb) / determ
-	db := (fm*fmb + fb*fmm) / determ
-	return dm, db
+		A[i] = append([]float64{1}, X[i]...)
+	}
+	return A
 }
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/csv"
+	"math"
+	"os"
+	"testing"
+)
+
+// readTestCSV reads the predictor columns xstr and the response column ystr
+// of a csv in the repository, dropping rows with missing values
+func readTestCSV(t *testing.T, fname string, xstr []string, ystr string) ([][]float64, []float64) {
+	t.Helper()
+	f, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	spec, err := parseMissing("drop", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	X, Y, _, _, _, err := readInValues(xstr, ystr, "", spec, csv.NewReader(f))
+	if err != nil {
+		t.Fatalf("%s: %v", fname, err)
+	}
+	return X, Y
+}
+
+func TestQRMatchesNewton(t *testing.T) {
+	tests := []struct {
+		fname string
+		xstr  []string
+		ystr  string
+	}{
+		{"regression_test.csv", []string{"0"}, "1"},
+		{"advertising.csv", []string{"TV", "Radio", "Newspaper"}, "Sales"},
+	}
+	for _, tt := range tests {
+		X, Y := readTestCSV(t, tt.fname, tt.xstr, tt.ystr)
+		newton, err := newtonSolver{Epsilon: .001, MaxIter: 100, LineSearch: armijoLineSearch}.Fit(X, Y)
+		if err != nil {
+			t.Fatalf("%s: newton: %v", tt.fname, err)
+		}
+		qr, err := qrSolver{}.Fit(X, Y)
+		if err != nil {
+			t.Fatalf("%s: qr: %v", tt.fname, err)
+		}
+		if math.Abs(newton.Intercept-qr.Intercept) > 1e-8 {
+			t.Errorf("%s: newton intercept %.12g, qr intercept %.12g", tt.fname, newton.Intercept, qr.Intercept)
+		}
+		for j := range qr.Coef {
+			if math.Abs(newton.Coef[j]-qr.Coef[j]) > 1e-8 {
+				t.Errorf("%s: newton coefficient %d %.12g, qr %.12g", tt.fname, j, newton.Coef[j], qr.Coef[j])
+			}
+		}
+	}
+}
+
+func TestAdvertisingCoefficients(t *testing.T) {
+	X, Y := readTestCSV(t, "advertising.csv", []string{"TV", "Radio", "Newspaper"}, "Sales")
+	// the estimates of the advertising data published with ISLR
+	want := []float64{2.9389, 0.0458, 0.1885, -0.0010}
+	solvers := []struct {
+		name   string
+		solver Solver
+	}{
+		{"newton", newtonSolver{Epsilon: .001, MaxIter: 100, LineSearch: armijoLineSearch}},
+		{"qr", qrSolver{}},
+	}
+	for _, s := range solvers {
+		result, err := s.solver.Fit(X, Y)
+		if err != nil {
+			t.Fatalf("%s: %v", s.name, err)
+		}
+		got := append([]float64{result.Intercept}, result.Coef...)
+		for j := range want {
+			if math.Abs(got[j]-want[j]) > 5e-5 {
+				t.Errorf("%s: parameter %d is %.6f, want %.4f", s.name, j, got[j], want[j])
+			}
+		}
+	}
+}
+
+func TestNewtonSmallScale(t *testing.T) {
+	// a clean line whose predictor is measured in tiny units, so the raw
+	// hessian has eigenvalues fourteen orders of magnitude apart
+	X := [][]float64{{1e-7}, {2e-7}, {3e-7}, {4e-7}, {5e-7}}
+	Y := []float64{1.3, 1.6, 1.7, 2.1, 2.3}
+	newton, err := newtonSolver{Epsilon: 1e-6, MaxIter: 100, LineSearch: armijoLineSearch}.Fit(X, Y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	qr, err := qrSolver{}.Fit(X, Y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(newton.Intercept-qr.Intercept) > 1e-9 || math.Abs(newton.Coef[0]-qr.Coef[0]) > 1e-9*math.Abs(qr.Coef[0]) {
+		t.Errorf("newton fit %g + %g x, qr %g + %g x", newton.Intercept, newton.Coef[0], qr.Intercept, qr.Coef[0])
+	}
+}
+
+func TestNewtonErrors(t *testing.T) {
+	line := [][]float64{{1}, {2}, {3}, {4}}
+	tests := []struct {
+		name      string
+		X         [][]float64
+		Y         []float64
+		maxIter   int
+		err       error
+		iteration int
+	}{
+		{"constant column", [][]float64{{3}, {3}, {3}, {3}}, []float64{1, 2, 3, 4}, 100, ErrSingularHessian, 0},
+		{"duplicate columns", [][]float64{{1, 1}, {2, 2}, {3, 3}, {4, 4}}, []float64{1, 2, 3, 5}, 100, ErrSingularHessian, 0},
+		{"NaN response", line, []float64{1, math.NaN(), 3, 4}, 100, ErrNonFinite, -1},
+		{"infinite predictor", [][]float64{{1}, {math.Inf(1)}, {3}, {4}}, []float64{1, 2, 3, 4}, 100, ErrNonFinite, -1},
+		// the first step lands on the minimum but is still above epsilon, so
+		// a second iteration is needed to see the step vanish
+		{"iteration cap", line, []float64{1, 2, 3, 5}, 1, ErrNotConverged, 1},
+	}
+	for _, tt := range tests {
+		_, err := newtonSolver{Epsilon: 1e-9, MaxIter: tt.maxIter, LineSearch: armijoLineSearch}.Fit(tt.X, tt.Y)
+		serr, ok := err.(*SolverError)
+		if !ok {
+			t.Fatalf("%s: got error %v, want a SolverError", tt.name, err)
+		}
+		if serr.Err != tt.err {
+			t.Errorf("%s: got %v, want %v", tt.name, serr.Err, tt.err)
+		}
+		if serr.Iteration != tt.iteration {
+			t.Errorf("%s: failed at iteration %d, want %d", tt.name, serr.Iteration, tt.iteration)
+		}
+	}
+}
+
+func TestSolveLinearScale(t *testing.T) {
+	// the second column is measured in units so small that every entry of it
+	// is below an absolute pivot tolerance
+	A := [][]float64{{2, 3e-14}, {1, 4e-14}}
+	b := []float64{8, 9}
+	x, err := solveLinear(A, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(x[0]-1) > 1e-12 || math.Abs(x[1]-2e14) > 1e2 {
+		t.Errorf("solveLinear = %v, want [1 2e14]", x)
+	}
+	if _, err := solveLinear([][]float64{{1, 2}, {2, 4}}, b); err != errSingularMatrix {
+		t.Errorf("solveLinear of a singular matrix returned %v, want %v", err, errSingularMatrix)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+// objective is a twice differentiable function of the parameters theta that
+// newtons method minimises. theta[0] is the intercept by convention
+type objective interface {
+	// Value returns the objective at theta
+	Value(theta []float64) float64
+	// Gradient returns the vector of first partial derivatives at theta
+	Gradient(theta []float64) []float64
+	// Hessian returns the matrix of second partial derivatives at theta
+	Hessian(theta []float64) [][]float64
+}
+
+// leastSquares is the mean squared error of a linear model of Y on the columns
+// of X. When W is set each squared error is weighted by W and the mean is
+// taken over the sum of the weights
+type leastSquares struct {
+	X [][]float64
+	Y []float64
+	W []float64
+}
+
+// Value implements objective
+func (o leastSquares) Value(theta []float64) float64 {
+	return weightedMeanSquaredError(o.X, o.Y, o.W, theta)
+}
+
+// Gradient implements objective
+func (o leastSquares) Gradient(theta []float64) []float64 {
+	grad := make([]float64, len(theta))
+	// z is the row of the design matrix, a leading one for the intercept
+	// followed by the values of the row
+	z := make([]float64, len(theta))
+	z[0] = 1
+	for i := range o.X {
+		copy(z[1:], o.X[i])
+		residual := o.Y[i] - dot(z, theta)
+		w := weightAt(o.W, i)
+		for j := range z {
+			grad[j] += -w * z[j] * residual
+		}
+	}
+	// multiply by the two constant that is almost always pulled out of the
+	// derivative equation and devide by n to normalize
+	n := sumWeights(o.W, len(o.X))
+	for j := range grad {
+		grad[j] = 2 * grad[j] / n
+	}
+	return grad
+}
+
+// Hessian implements objective. The hessian of the squared error does not
+// depend on theta
+func (o leastSquares) Hessian(theta []float64) [][]float64 {
+	k := len(theta)
+	hess := make([][]float64, k)
+	for j := range hess {
+		hess[j] = make([]float64, k)
+	}
+	z := make([]float64, k)
+	z[0] = 1
+	for i := range o.X {
+		copy(z[1:], o.X[i])
+		w := weightAt(o.W, i)
+		for j := range z {
+			for l := range z {
+				hess[j][l] += w * z[j] * z[l]
+			}
+		}
+	}
+	n := sumWeights(o.W, len(o.X))
+	for j := range hess {
+		for l := range hess[j] {
+			hess[j][l] = 2 * hess[j][l] / n
+		}
+	}
+	return hess
+}
+
+// meanSquaredError returns the mean squared error of the linear model with
+// intercept theta[0] and coefficients theta[1:] on the data
+func meanSquaredError(X [][]float64, Y []float64, theta []float64) (loss float64) {
+	return weightedMeanSquaredError(X, Y, nil, theta)
+}
+
+// weightedMeanSquaredError returns the mean squared error of the linear model
+// with each row weighted by W, or unweighted when W is nil
+func weightedMeanSquaredError(X [][]float64, Y, W []float64, theta []float64) (loss float64) {
+	n := sumWeights(W, len(X))
+	for i := range X {
+		residual := Y[i] - predict(theta[1:], X[i], theta[0])
+		loss += weightAt(W, i) * residual * residual / n
+	}
+	return
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// fitOutput is the document written by fit -format json
+type fitOutput struct {
+	Input    string   `json:"input"`
+	Solver   string   `json:"solver"`
+	Features []string `json:"features"`
+	Response string   `json:"response"`
+	// Weights is the column of row weights of a weighted fit
+	Weights   string    `json:"weights,omitempty"`
+	Coef      []float64 `json:"coefficients"`
+	Intercept float64   `json:"intercept"`
+	// Condition is the condition number of the design matrix when the solver
+	// computed it
+	Condition float64 `json:"condition_number,omitempty"`
+	// RobustWeights and RobustScale are the final weight of each row and the
+	// MAD scale of the residuals of a robust fit
+	RobustWeights []float64 `json:"robust_weights,omitempty"`
+	RobustScale   float64   `json:"robust_scale,omitempty"`
+	// Inliers marks the rows a ransac fit used
+	Inliers []bool `json:"inliers,omitempty"`
+	// Resampled is the bootstrap or jackknife inference when it was asked for
+	Resampled *resampledInference `json:"resampled,omitempty"`
+	// Classification measures how well a logistic regression classifies the
+	// rows it was fitted to
+	Classification *classification `json:"classification,omitempty"`
+	// Quantiles are the fits of quantile regression, one per quantile
+	Quantiles []quantileFit `json:"quantiles,omitempty"`
+	Summary   FitSummary    `json:"summary"`
+	Trace     []Iteration   `json:"trace"`
+	Quality   DataQuality   `json:"data_quality"`
+	// Plots and Model are the paths of the files written by the fit
+	Plots []string `json:"plots"`
+	Model string   `json:"model,omitempty"`
+}
+
+// checkFormat returns an error unless format is one fit can write
+func checkFormat(format string) error {
+	switch format {
+	case "text", "json", "csv":
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q, must be text, json or csv", format)
+}
+
+// writeJSON writes the fit output to w as a single json document
+func (o *fitOutput) writeJSON(w io.Writer) error {
+	if o.Trace == nil {
+		o.Trace = []Iteration{}
+	}
+	if o.Plots == nil {
+		o.Plots = []string{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(o)
+}
+
+// writeCSV writes the coefficient table of the fit output to w with a row for
+// the intercept followed by each coefficient, or the estimates of each
+// quantile of several
+func (o *fitOutput) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if len(o.Quantiles) > 1 {
+		// several quantiles only have estimates, a row for each of every
+		// quantile
+		cw.Write([]string{"quantile", "name", "estimate"})
+		for _, q := range o.Quantiles {
+			tau := formatFloat(q.Tau)
+			cw.Write([]string{tau, "Intercept", formatFloat(q.Intercept)})
+			for j, m := range q.Coef {
+				cw.Write([]string{tau, o.Features[j], formatFloat(m)})
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	cw.Write([]string{"name", "estimate", "std_err", "t", "p_value", "lower", "upper"})
+	if o.Summary.Coefficients != nil {
+		for _, c := range o.Summary.Coefficients {
+			cw.Write([]string{c.Name, formatFloat(c.Estimate), formatFloat(c.StdErr), formatFloat(c.TStat),
+				formatFloat(c.PValue), formatFloat(c.Lower), formatFloat(c.Upper)})
+		}
+	} else {
+		// without inference only the estimates are known
+		cw.Write([]string{"Intercept", formatFloat(o.Intercept), "", "", "", "", ""})
+		for j, m := range o.Coef {
+			cw.Write([]string{o.Features[j], formatFloat(m), "", "", "", "", ""})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		out  fitOutput
+		want string
+	}{
+		{
+			"inference",
+			fitOutput{
+				Features: []string{"TV", "Radio"},
+				Summary: FitSummary{Coefficients: []CoefficientStats{
+					{Name: "Intercept", Estimate: 2.5, StdErr: 0.5, TStat: 5, PValue: 0.0001, Lower: 1.5, Upper: 3.5},
+					{Name: "TV", Estimate: 0.046, StdErr: 0.0014, TStat: 32.8, PValue: 1e-80, Lower: 0.043, Upper: 0.049},
+					{Name: "Radio", Estimate: -0.25, StdErr: 0.125, TStat: -2, PValue: 0.047, Lower: -0.5, Upper: 0},
+				}},
+			},
+			"name,estimate,std_err,t,p_value,lower,upper\n" +
+				"Intercept,2.5,0.5,5,0.0001,1.5,3.5\n" +
+				"TV,0.046,0.0014,32.8,1e-80,0.043,0.049\n" +
+				"Radio,-0.25,0.125,-2,0.047,-0.5,0\n",
+		},
+		{
+			"estimates only",
+			fitOutput{Features: []string{"x"}, Coef: []float64{17.25}, Intercept: -2.5},
+			"name,estimate,std_err,t,p_value,lower,upper\n" +
+				"Intercept,-2.5,,,,,\n" +
+				"x,17.25,,,,,\n",
+		},
+		{
+			"several quantiles",
+			fitOutput{Features: []string{"x"}, Quantiles: []quantileFit{
+				{Tau: 0.1, Coef: []float64{1.5}, Intercept: -1},
+				{Tau: 0.9, Coef: []float64{2.5}, Intercept: 3},
+			}},
+			"quantile,name,estimate\n" +
+				"0.1,Intercept,-1\n" +
+				"0.1,x,1.5\n" +
+				"0.9,Intercept,3\n" +
+				"0.9,x,2.5\n",
+		},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := tt.out.writeCSV(&buf); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%s: wrote\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,21 +1,65 @@
 package main
 
 import (
+	"fmt"
+	"image/color"
 	"log"
+	"math"
 
 	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/palette"
 	"gonum.org/v1/plot/plotter"
 	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
 )
 
-// plotRegression takes plotter.XYs pairs for bo
-func plotRegression(pts plotter.XYs, linepts plotter.XYs, fname string) {
+// band is the shaded region between a lower and an upper curve sampled at the
+// same x values in increasing order
+type band struct {
+	Label string
+	Lower plotter.XYs
+	Upper plotter.XYs
+	Color color.Color
+}
+
+// polygon returns the band as a filled polygon tracing the upper curve left to
+// right and the lower curve back
+func (b band) polygon() (*plotter.Polygon, error) {
+	ring := make(plotter.XYs, 0, len(b.Upper)+len(b.Lower))
+	ring = append(ring, b.Upper...)
+	for i := len(b.Lower) - 1; i >= 0; i-- {
+		ring = append(ring, b.Lower[i])
+	}
+	poly, err := plotter.NewPolygon(ring)
+	if err != nil {
+		return nil, err
+	}
+	poly.Color = b.Color
+	poly.LineStyle.Width = 0
+	return poly, nil
+}
+
+// curve is a fitted function of x drawn through the observations and the
+// label of its legend entry
+type curve struct {
+	Label string
+	F     func(float64) float64
+}
+
+// plotRegression takes plotter.XYs pairs for the observations and saves them
+// as a scatter plot to fname with the fitted curves drawn through them, in
+// black for a single curve and in colours from a palette for several. The
+// bands are shaded beneath the points in the order given. When weights is not
+// nil each point is coloured by its weight in [0, 1], from red at zero to
+// black at one, and when inliers is not nil the outliers are drawn as red
+// crosses
+func plotRegression(pts plotter.XYs, weights []float64, inliers []bool, curves []curve, bands []band, xLabel, yLabel, fname string) {
 	p, err := plot.New()
 	if err != nil {
 		log.Fatal(err)
 	}
-	p.X.Label.Text = "TV"
-	p.Y.Label.Text = "Sales"
+	p.X.Label.Text = xLabel
+	p.Y.Label.Text = yLabel
 	p.Add(plotter.NewGrid())
 	// Add the scatter plot points for the observations.
 	s, err := plotter.NewScatter(pts)
@@ -23,16 +67,121 @@ func plotRegression(pts plotter.XYs, linepts plotter.XYs, fname string) {
 		log.Fatal(err)
 	}
 	s.GlyphStyle.Radius = vg.Points(3)
-	// Add the line plot points for the predictions.
-	l, err := plotter.NewLine(linepts)
-	if err != nil {
-		log.Fatal(err)
+	outlier := s.GlyphStyle
+	outlier.Color, outlier.Shape = weightColor(0), draw.CrossGlyph{}
+	switch {
+	case weights != nil:
+		s.GlyphStyleFunc = func(i int) draw.GlyphStyle {
+			style := s.GlyphStyle
+			style.Color = weightColor(weights[i])
+			return style
+		}
+	case inliers != nil:
+		s.GlyphStyleFunc = func(i int) draw.GlyphStyle {
+			if !inliers[i] {
+				return outlier
+			}
+			return s.GlyphStyle
+		}
+	}
+	// Add the curves of the predictions.
+	lines := make([]*plotter.Function, len(curves))
+	var colors []color.Color
+	if len(curves) > 1 {
+		colors = palette.Rainbow(len(curves), palette.Blue, palette.Red, 1, 0.8, 1).Colors()
+	}
+	for i, c := range curves {
+		l := plotter.NewFunction(c.F)
+		l.Samples = 200
+		l.LineStyle.Width = vg.Points(1)
+		l.LineStyle.Dashes = []vg.Length{vg.Points(5), vg.Points(5)}
+		if colors != nil {
+			l.LineStyle.Color = colors[i]
+		}
+		lines[i] = l
+	}
+	// Shade the bands first so the points and line are drawn over them.
+	for _, b := range bands {
+		poly, err := b.polygon()
+		if err != nil {
+			log.Fatal(err)
+		}
+		p.Add(poly)
+		p.Legend.Add(b.Label, poly)
 	}
-	l.LineStyle.Width = vg.Points(1)
-	l.LineStyle.Dashes = []vg.Length{vg.Points(5), vg.Points(5)}
 	// Save the plot to a PNG file.
-	p.Add(s, l)
+	p.Add(s)
+	for _, l := range lines {
+		p.Add(l)
+	}
+	// legendKey adds a legend entry for the points drawn with style
+	legendKey := func(label string, style draw.GlyphStyle) {
+		key, err := plotter.NewScatter(plotter.XYs{{}})
+		if err != nil {
+			log.Fatal(err)
+		}
+		key.GlyphStyle = style
+		p.Legend.Add(label, key)
+	}
+	switch {
+	case weights != nil:
+		// a legend entry for each end of the colour scale
+		for _, w := range []float64{1, 0} {
+			style := s.GlyphStyle
+			style.Color = weightColor(w)
+			legendKey(fmt.Sprintf("weight %g", w), style)
+		}
+	case inliers != nil:
+		legendKey("inliers", s.GlyphStyle)
+		legendKey("outliers", outlier)
+	default:
+		p.Legend.Add("observations", s)
+	}
+	for i, l := range lines {
+		p.Legend.Add(curves[i].Label, l)
+	}
+	p.Legend.Top = true
+	p.Legend.Left = true
 	if err := p.Save(4*vg.Inch, 4*vg.Inch, fname); err != nil {
 		log.Fatal(err)
 	}
 }
+
+// weightColor blends from red for a weight of zero to black for a weight of one
+func weightColor(w float64) color.Color {
+	w = math.Max(0, math.Min(1, w))
+	return color.RGBA{R: uint8(220 * (1 - w)), A: 255}
+}
+
+// plotPath saves the trajectory of each standardized coefficient along the
+// regularization path against alpha on a log scale to fname
+func plotPath(path []pathPoint, features []string, fname string) {
+	p, err := plot.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	p.X.Label.Text = "alpha"
+	p.Y.Label.Text = "standardized coefficient"
+	p.X.Scale = plot.LogScale{}
+	p.X.Tick.Marker = plot.LogTicks{}
+	p.Add(plotter.NewGrid())
+	colors := palette.Rainbow(len(features), palette.Red, palette.Magenta, 1, 0.8, 1).Colors()
+	for j, name := range features {
+		pts := make(plotter.XYs, len(path))
+		for i, pt := range path {
+			pts[i].X, pts[i].Y = pt.Alpha, pt.Standardized[j]
+		}
+		l, err := plotter.NewLine(pts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		l.LineStyle.Width = vg.Points(1.5)
+		l.LineStyle.Color = colors[j]
+		p.Add(l)
+		p.Legend.Add(name, l)
+	}
+	p.Legend.Top = true
+	if err := p.Save(6*vg.Inch, 4*vg.Inch, fname); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// polyBasis expands a single predictor into polynomial features of degree one
+// through Degree. Raw bases use the powers of x, which grow ill-conditioned
+// with the degree, while orthogonal bases use polynomials that are orthogonal
+// over the training x, built with the three term recurrence
+//
+//	P_0(x) = 1, P_1(x) = x - Alpha_0
+//	P_j+1(x) = (x - Alpha_j) P_j(x) - (Norm2_j / Norm2_j-1) P_j-1(x)
+//
+// where Alpha_j is the mean of x weighted by P_j^2 and Norm2_j is the sum of
+// P_j^2 over the training x. Each P_j is then scaled by Scale_j to have a mean
+// square of one over the training x, so the features are orthonormal and the
+// hessian of the least squares fit stays well conditioned at any degree
+type polyBasis struct {
+	Column     string    `json:"column"`
+	Degree     int       `json:"degree"`
+	Orthogonal bool      `json:"orthogonal"`
+	Alpha      []float64 `json:"alpha,omitempty"`
+	Norm2      []float64 `json:"norm2,omitempty"`
+	Scale      []float64 `json:"scale,omitempty"`
+}
+
+// newPolyBasis returns the polynomial basis of the given degree for the column
+// named column, fitting the recurrence of an orthogonal basis to the training
+// values x
+func newPolyBasis(column string, x []float64, degree int, orthogonal bool) (*polyBasis, error) {
+	if degree < 1 {
+		return nil, fmt.Errorf("polynomial degree must be at least 1, got %d", degree)
+	}
+	p := &polyBasis{Column: column, Degree: degree, Orthogonal: orthogonal}
+	if !orthogonal {
+		return p, nil
+	}
+	// evaluate the basis at the training x one degree at a time, prev and cur
+	// hold P_j-1 and P_j at every x
+	n := len(x)
+	prev := make([]float64, n)
+	cur := make([]float64, n)
+	for i := range cur {
+		cur[i] = 1
+	}
+	for j := 0; j < degree; j++ {
+		var norm2, weighted float64
+		for i := range x {
+			norm2 += cur[i] * cur[i]
+			weighted += x[i] * cur[i] * cur[i]
+		}
+		if norm2 == 0 {
+			return nil, fmt.Errorf("column %s has fewer than %d distinct values for a degree %d orthogonal basis", column, degree+1, degree)
+		}
+		p.Alpha = append(p.Alpha, weighted/norm2)
+		p.Norm2 = append(p.Norm2, norm2)
+		next := make([]float64, n)
+		for i := range x {
+			next[i] = (x[i] - p.Alpha[j]) * cur[i]
+			if j > 0 {
+				next[i] -= norm2 / p.Norm2[j-1] * prev[i]
+			}
+		}
+		prev, cur = cur, next
+	}
+	var norm2 float64
+	for i := range cur {
+		norm2 += cur[i] * cur[i]
+	}
+	if norm2 == 0 {
+		return nil, fmt.Errorf("column %s has fewer than %d distinct values for a degree %d orthogonal basis", column, degree+1, degree)
+	}
+	p.Norm2 = append(p.Norm2, norm2)
+	for j := 1; j <= degree; j++ {
+		p.Scale = append(p.Scale, math.Sqrt(float64(n)/p.Norm2[j]))
+	}
+	return p, nil
+}
+
+// expand returns the features of x, the basis polynomials of degree one
+// through Degree
+func (p *polyBasis) expand(x float64) []float64 {
+	features := make([]float64, p.Degree)
+	if !p.Orthogonal {
+		for j := range features {
+			features[j] = math.Pow(x, float64(j+1))
+		}
+		return features
+	}
+	prev, cur := 0.0, 1.0
+	for j := range features {
+		next := (x - p.Alpha[j]) * cur
+		if j > 0 {
+			next -= p.Norm2[j] / p.Norm2[j-1] * prev
+		}
+		prev, cur = cur, next
+		features[j] = cur * p.Scale[j]
+	}
+	return features
+}
+
+// expandAll returns the features of the first column of every row of X
+func (p *polyBasis) expandAll(X [][]float64) [][]float64 {
+	features := make([][]float64, len(X))
+	for i := range X {
+		features[i] = p.expand(X[i][0])
+	}
+	return features
+}
+
+// names returns the name of each feature of the basis
+func (p *polyBasis) names() []string {
+	names := make([]string, p.Degree)
+	for j := range names {
+		switch {
+		case p.Orthogonal:
+			names[j] = fmt.Sprintf("poly%d(%s)", j+1, p.Column)
+		case j == 0:
+			names[j] = p.Column
+		default:
+			names[j] = fmt.Sprintf("%s^%d", p.Column, j+1)
+		}
+	}
+	return names
+}
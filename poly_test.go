@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestOrthogonalBasisOrthonormal(t *testing.T) {
+	x := []float64{0.5, 1, 1.5, 3, 4, 4.5, 6, 7.5, 8, 10}
+	const degree = 4
+	p, err := newPolyBasis("x", x, degree, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// with the constant as P_0 the mean product of every pair of features
+	// over the training x is one on the diagonal and zero off it
+	features := make([][]float64, len(x))
+	for i := range x {
+		features[i] = append([]float64{1}, p.expand(x[i])...)
+	}
+	for j := 0; j <= degree; j++ {
+		for l := 0; l <= degree; l++ {
+			var mean float64
+			for i := range x {
+				mean += features[i][j] * features[i][l] / float64(len(x))
+			}
+			want := 0.0
+			if j == l {
+				want = 1
+			}
+			if math.Abs(mean-want) > 1e-9 {
+				t.Errorf("mean of P_%d P_%d is %g, want %g", j, l, mean, want)
+			}
+		}
+	}
+}
+
+func TestPolyBasesSameFit(t *testing.T) {
+	// a cubic with noise, so the fit does not pass through every point
+	x := []float64{-2, -1.5, -1, -0.5, 0, 0.5, 1, 1.5, 2, 2.5}
+	Y := make([]float64, len(x))
+	for i, v := range x {
+		Y[i] = 1 - 2*v + 0.5*v*v*v + 0.1*math.Sin(7*v)
+	}
+	X := make([][]float64, len(x))
+	for i := range x {
+		X[i] = []float64{x[i]}
+	}
+	var fitted [2][]float64
+	for k, orthogonal := range []bool{false, true} {
+		p, err := newPolyBasis("x", x, 3, orthogonal)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := qrSolver{}.Fit(p.expandAll(X), Y)
+		if err != nil {
+			t.Fatalf("orthogonal %v: %v", orthogonal, err)
+		}
+		for i := range x {
+			fitted[k] = append(fitted[k], predict(result.Coef, p.expand(x[i]), result.Intercept))
+		}
+	}
+	for i := range x {
+		if math.Abs(fitted[0][i]-fitted[1][i]) > 1e-9 {
+			t.Errorf("at x = %g the raw basis fits %.12g and the orthogonal basis %.12g", x[i], fitted[0][i], fitted[1][i])
+		}
+	}
+}
+
+func TestPolyBasisErrors(t *testing.T) {
+	if _, err := newPolyBasis("x", []float64{1, 2, 3}, 0, false); err == nil {
+		t.Error("a degree zero basis was accepted")
+	}
+	// three distinct values only support a quadratic
+	x := []float64{1, 2, 3, 1, 2, 3}
+	if _, err := newPolyBasis("x", x, 2, true); err != nil {
+		t.Errorf("degree 2 on three distinct values: %v", err)
+	}
+	if _, err := newPolyBasis("x", x, 3, true); err == nil {
+		t.Error("a degree 3 orthogonal basis was built from three distinct values")
+	}
+}
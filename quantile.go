@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// parseQuantiles parses a comma separated list of quantiles, each strictly
+// between zero and one and none repeated
+func parseQuantiles(s string) ([]float64, error) {
+	var taus []float64
+	seen := map[float64]bool{}
+	for _, field := range strings.Split(s, ",") {
+		tau, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quantile %q", field)
+		}
+		if tau <= 0 || tau >= 1 {
+			return nil, fmt.Errorf("quantile %g must be between 0 and 1", tau)
+		}
+		if seen[tau] {
+			return nil, fmt.Errorf("quantile %g given twice", tau)
+		}
+		seen[tau] = true
+		taus = append(taus, tau)
+	}
+	return taus, nil
+}
+
+// checkLoss returns the mean of the check function of the quantile tau over
+// the residuals of the predictions yPred of Y, weighting the rows by W when it
+// is not nil. The check function is tau*r for positive residuals and
+// (tau-1)*r for negative ones, so it is half the absolute error at the median
+func checkLoss(Y, yPred, W []float64, tau float64) float64 {
+	var loss float64
+	n := sumWeights(W, len(Y))
+	for i, y := range Y {
+		r := y - yPred[i]
+		if r < 0 {
+			loss += weightAt(W, i) * (tau - 1) * r / n
+		} else {
+			loss += weightAt(W, i) * tau * r / n
+		}
+	}
+	return loss
+}
+
+// weightedQuantile returns the smallest value of Y at which the share of the
+// weight W at or below it reaches tau, which minimises the check loss of a
+// constant
+func weightedQuantile(Y, W []float64, tau float64) float64 {
+	order := make([]int, len(Y))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return Y[order[a]] < Y[order[b]] })
+	total := sumWeights(W, len(Y))
+	var cum float64
+	for _, i := range order {
+		cum += weightAt(W, i)
+		if cum >= tau*total {
+			return Y[i]
+		}
+	}
+	return Y[order[len(order)-1]]
+}
+
+// pseudoR1 returns Koenker and Machado's goodness of fit of a quantile
+// regression, one minus its check loss over that of the quantile of Y alone
+func pseudoR1(Y, yPred, W []float64, tau float64) float64 {
+	q := weightedQuantile(Y, W, tau)
+	base := make([]float64, len(Y))
+	for i := range base {
+		base[i] = q
+	}
+	return 1 - checkLoss(Y, yPred, W, tau)/checkLoss(Y, base, W, tau)
+}
+
+// quantileSolver fits the conditional quantile Tau of Y by minimising the check
+// loss with the majorize-minimize algorithm of Hunter and Lange. Each
+// iteration bounds the loss above by a weighted least squares problem at the
+// current residuals r, with weights 1/(|r|+e) and the response shifted by
+// (2*Tau-1)*(|r|+e), and solves it with newtons method. The small perturbation
+// e keeps the weights of rows fitted exactly finite. It starts from least
+// squares, stops once an iteration moves the parameters less than Epsilon and
+// fails after MaxIter iterations
+type quantileSolver struct {
+	Tau        float64
+	Epsilon    float64
+	MaxIter    int
+	LineSearch lineSearch
+}
+
+// Fit implements Solver
+func (s quantileSolver) Fit(X [][]float64, Y []float64) (*Result, error) {
+	return s.FitWeighted(X, Y, nil)
+}
+
+// FitWeighted implements weightedSolver, weighting the check loss of each row
+// by its weight in W
+func (s quantileSolver) FitWeighted(X [][]float64, Y, W []float64) (*Result, error) {
+	if err := checkFinite(X, Y); err != nil {
+		return nil, err
+	}
+	if s.Tau <= 0 || s.Tau >= 1 {
+		return nil, fmt.Errorf("quantile %g must be between 0 and 1", s.Tau)
+	}
+	theta, _, err := minimizeNewton(leastSquares{X: X, Y: Y, W: W}, make([]float64, len(X[0])+1), s.Epsilon, s.MaxIter, s.LineSearch)
+	if err != nil {
+		return nil, err
+	}
+	n := len(X)
+	yPred, shifted, fitWeights := make([]float64, n), make([]float64, n), make([]float64, n)
+	predictAll := func() {
+		for i := range X {
+			yPred[i] = predict(theta[1:], X[i], theta[0])
+		}
+	}
+	predictAll()
+	// the perturbation is small relative to the spread of the residuals
+	var spread float64
+	for i, y := range Y {
+		spread += math.Abs(y-yPred[i]) / float64(n)
+	}
+	perturbation := 1e-6 * math.Max(spread, 1e-12)
+	var trace []Iteration
+	prev := make([]float64, len(theta))
+	for it := 0; it < s.MaxIter; it++ {
+		for i, y := range Y {
+			a := math.Abs(y-yPred[i]) + perturbation
+			fitWeights[i] = weightAt(W, i) / a
+			shifted[i] = y + (2*s.Tau-1)*a
+		}
+		copy(prev, theta)
+		if theta, _, err = minimizeNewton(leastSquares{X: X, Y: shifted, W: fitWeights}, theta, s.Epsilon, s.MaxIter, s.LineSearch); err != nil {
+			if serr, ok := err.(*SolverError); ok {
+				serr.Detail = fmt.Sprintf("majorization %d: %s", it, serr.Detail)
+			}
+			return nil, err
+		}
+		for j := range prev {
+			prev[j] = theta[j] - prev[j]
+		}
+		step := norm(prev)
+		predictAll()
+		trace = append(trace, newIteration(it, step, 1, checkLoss(Y, yPred, W, s.Tau), theta))
+		if step < s.Epsilon {
+			return &Result{Coef: theta[1:], Intercept: theta[0], Trace: trace}, nil
+		}
+	}
+	return nil, &SolverError{
+		Err:       ErrNotConverged,
+		Iteration: len(trace),
+		Detail:    fmt.Sprintf("quantile %g still moving after %d majorizations", s.Tau, len(trace)),
+	}
+}
+
+// quantileFit is the fit of a single quantile of several
+type quantileFit struct {
+	Tau       float64   `json:"quantile"`
+	Coef      []float64 `json:"coefficients"`
+	Intercept float64   `json:"intercept"`
+	CheckLoss float64   `json:"check_loss"`
+	PseudoR1  float64   `json:"pseudo_r1"`
+}
+
+// MarshalJSON implements json.Marshaler, writing a check loss or pseudo R1
+// that is NaN or infinite, as the pseudo R1 of a perfect fit is, as null
+func (q quantileFit) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Tau       float64   `json:"quantile"`
+		Coef      []float64 `json:"coefficients"`
+		Intercept float64   `json:"intercept"`
+		CheckLoss *float64  `json:"check_loss"`
+		PseudoR1  *float64  `json:"pseudo_r1"`
+	}{q.Tau, q.Coef, q.Intercept, nullable(q.CheckLoss), nullable(q.PseudoR1)})
+}
+
+// newQuantileFit returns the fit of the quantile tau with its check loss and
+// pseudo R1 on the rows it was fitted to
+func newQuantileFit(tau float64, X [][]float64, Y, W []float64, coef []float64, b float64) quantileFit {
+	yPred := make([]float64, len(X))
+	for i := range X {
+		yPred[i] = predict(coef, X[i], b)
+	}
+	return quantileFit{Tau: tau, Coef: coef, Intercept: b, CheckLoss: checkLoss(Y, yPred, W, tau), PseudoR1: pseudoR1(Y, yPred, W, tau)}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestWeightedQuantile(t *testing.T) {
+	tests := []struct {
+		Y, W []float64
+		tau  float64
+		want float64
+	}{
+		{[]float64{3, 1, 2, 5, 4}, nil, 0.5, 3},
+		{[]float64{3, 1, 2, 5, 4}, nil, 0.2, 1},
+		{[]float64{3, 1, 2, 5, 4}, nil, 0.21, 2},
+		{[]float64{3, 1, 2, 5, 4}, nil, 0.99, 5},
+		// the weight of the largest value outweighs the rest
+		{[]float64{1, 2, 10}, []float64{1, 1, 3}, 0.5, 10},
+		{[]float64{1, 2, 10}, []float64{1, 1, 3}, 0.4, 2},
+	}
+	for _, tt := range tests {
+		if got := weightedQuantile(tt.Y, tt.W, tt.tau); got != tt.want {
+			t.Errorf("weightedQuantile(%v, %v, %g) = %g, want %g", tt.Y, tt.W, tt.tau, got, tt.want)
+		}
+	}
+}
+
+func TestCheckLoss(t *testing.T) {
+	Y := []float64{1, 2, 3, 4}
+	yPred := []float64{2, 2, 2, 2}
+	tests := []struct {
+		W    []float64
+		tau  float64
+		want float64
+	}{
+		// residuals -1, 0, 1 and 2
+		{nil, 0.5, (0.5 + 0.5 + 1) / 4},
+		{nil, 0.9, (0.1 + 0.9 + 1.8) / 4},
+		{[]float64{2, 1, 1, 0}, 0.25, (2*0.75 + 0.25) / 4},
+	}
+	for _, tt := range tests {
+		if got := checkLoss(Y, yPred, tt.W, tt.tau); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("checkLoss with weights %v at %g = %g, want %g", tt.W, tt.tau, got, tt.want)
+		}
+	}
+}
+
+func TestQuantileSolver(t *testing.T) {
+	// ten rows at x = 0 are 1..10 and ten at x = 1 are 3..12, so the line
+	// through the quantile of each group is the quantile regression. With ten
+	// rows the 0.75 quantile is the eighth and the 0.25 quantile the third
+	var X [][]float64
+	var Y []float64
+	for x := 0; x <= 1; x++ {
+		for k := 1; k <= 10; k++ {
+			X = append(X, []float64{float64(x)})
+			Y = append(Y, float64(2*x+k))
+		}
+	}
+	tests := []struct {
+		X    [][]float64
+		Y    []float64
+		tau  float64
+		b, m float64
+	}{
+		{X, Y, 0.75, 8, 2},
+		{X, Y, 0.25, 3, 2},
+		// the median line passes through the four rows on y = 1 + 2x and
+		// ignores the fifth however far off it is
+		{[][]float64{{1}, {2}, {3}, {4}, {5}}, []float64{3, 5, 100, 9, 11}, 0.5, 1, 2},
+	}
+	for _, tt := range tests {
+		s := quantileSolver{Tau: tt.tau, Epsilon: 1e-9, MaxIter: 1000, LineSearch: armijoLineSearch}
+		result, err := s.Fit(tt.X, tt.Y)
+		if err != nil {
+			t.Fatalf("quantile %g: %v", tt.tau, err)
+		}
+		if math.Abs(result.Intercept-tt.b) > 1e-3 || math.Abs(result.Coef[0]-tt.m) > 1e-3 {
+			t.Errorf("quantile %g: line %.6g + %.6gx, want %g + %gx", tt.tau, result.Intercept, result.Coef[0], tt.b, tt.m)
+		}
+	}
+}
+
+func TestQuantileFitJSONConstant(t *testing.T) {
+	// every row of a constant response is fitted exactly, so the check loss
+	// of the fit and of the quantile alone are both zero and pseudo R1 is 0/0
+	X := [][]float64{{1}, {2}, {3}, {4}}
+	Y := []float64{5, 5, 5, 5}
+	s := quantileSolver{Tau: 0.5, Epsilon: 1e-9, MaxIter: 1000, LineSearch: armijoLineSearch}
+	result, err := s.Fit(X, Y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := newQuantileFit(0.5, X, Y, nil, result.Coef, result.Intercept)
+	if !math.IsNaN(q.PseudoR1) {
+		t.Fatalf("pseudo R1 of a constant response is %g, want NaN", q.PseudoR1)
+	}
+	data, err := json.Marshal(fitOutput{Quantiles: []quantileFit{q}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"pseudo_r1":null`) || !strings.Contains(string(data), `"quantile":0.5`) {
+		t.Errorf("json of the fit is %s", data)
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// regularizedSolver fits an elastic net, minimising half the mean squared
+// error plus Alpha*(L1Ratio*|b|_1 + (1-L1Ratio)/2*|b|_2^2) where b are the
+// coefficients of the standardized columns. The intercept is not penalized.
+// An L1Ratio of zero is ridge regression, which is smooth and solved with
+// newtons method, anything above uses coordinate descent with L1Ratio one
+// being the lasso. Coordinate descent stops once a sweep moves the parameters
+// less than Epsilon and fails after MaxIter sweeps
+type regularizedSolver struct {
+	Alpha      float64
+	L1Ratio    float64
+	Epsilon    float64
+	MaxIter    int
+	LineSearch lineSearch
+}
+
+// Fit implements Solver
+func (s regularizedSolver) Fit(X [][]float64, Y []float64) (*Result, error) {
+	return s.FitWeighted(X, Y, nil)
+}
+
+// FitWeighted implements weightedSolver. The columns are standardized with the
+// weighted mean and standard deviation and the mean squared error is weighted
+func (s regularizedSolver) FitWeighted(X [][]float64, Y, W []float64) (*Result, error) {
+	if err := checkFinite(X, Y); err != nil {
+		return nil, err
+	}
+	sc, err := newScaler(X, W)
+	if err != nil {
+		return nil, err
+	}
+	Z := sc.transform(X)
+	var theta []float64
+	var trace []Iteration
+	if s.L1Ratio == 0 {
+		obj := ridgePenalty{leastSquares: leastSquares{X: Z, Y: Y, W: W}, Alpha: s.Alpha}
+		theta, trace, err = minimizeNewton(obj, make([]float64, len(X[0])+1), s.Epsilon, s.MaxIter, s.LineSearch)
+	} else {
+		theta, trace, err = s.coordinateDescent(Z, Y, W, make([]float64, len(X[0])))
+	}
+	if err != nil {
+		return nil, err
+	}
+	// the trace follows the parameters of the original columns
+	for i := range trace {
+		trace[i].Theta = sc.unscale(trace[i].Theta)
+	}
+	orig := sc.unscale(theta)
+	return &Result{Coef: orig[1:], Intercept: orig[0], Trace: trace}, nil
+}
+
+// coordinateDescent minimises the elastic net on the standardized columns Z
+// one coefficient at a time, starting from the coefficients beta. Each
+// coefficient is set to the soft thresholded least squares fit of the partial
+// residual, which has a closed form because every column has unit variance.
+// The intercept is the mean of Y since the columns are centred. Rows are
+// weighted by W, or unweighted when it is nil, and Z must have been
+// standardized with the same weights
+func (s regularizedSolver) coordinateDescent(Z [][]float64, Y, W []float64, beta []float64) ([]float64, []Iteration, error) {
+	n, p := sumWeights(W, len(Z)), len(beta)
+	theta := make([]float64, p+1)
+	for i, y := range Y {
+		theta[0] += weightAt(W, i) * y / n
+	}
+	copy(theta[1:], beta)
+	residual := make([]float64, len(Z))
+	for i := range Z {
+		residual[i] = Y[i] - dot(Z[i], theta[1:]) - theta[0]
+	}
+	l1, l2 := s.Alpha*s.L1Ratio, s.Alpha*(1-s.L1Ratio)
+	prev := make([]float64, p+1)
+	var trace []Iteration
+	for sweep := 0; sweep < s.MaxIter; sweep++ {
+		copy(prev, theta)
+		for j := 1; j <= p; j++ {
+			// rho is the least squares coefficient of the partial residual
+			// that leaves out column j
+			var rho float64
+			for i := range Z {
+				rho += weightAt(W, i) * Z[i][j-1] * residual[i] / n
+			}
+			rho += theta[j]
+			next := softThreshold(rho, l1) / (1 + l2)
+			if delta := next - theta[j]; delta != 0 {
+				for i := range Z {
+					residual[i] -= Z[i][j-1] * delta
+				}
+				theta[j] = next
+			}
+		}
+		for j := range prev {
+			prev[j] = theta[j] - prev[j]
+		}
+		step := norm(prev)
+		if !isFinite(theta) {
+			return nil, trace, &SolverError{Err: ErrNonFinite, Iteration: sweep, Detail: "coefficients overflowed"}
+		}
+		trace = append(trace, newIteration(sweep, step, 1, elasticNetLoss(residual, W, theta[1:], l1, l2), theta))
+		if step < s.Epsilon {
+			return theta, trace, nil
+		}
+	}
+	return nil, trace, &SolverError{
+		Err:       ErrNotConverged,
+		Iteration: len(trace),
+		Detail:    fmt.Sprintf("coordinate descent still moving after %d sweeps", len(trace)),
+	}
+}
+
+// softThreshold shrinks x towards zero by t, returning zero when |x| <= t
+func softThreshold(x, t float64) float64 {
+	switch {
+	case x > t:
+		return x - t
+	case x < -t:
+		return x + t
+	}
+	return 0
+}
+
+// elasticNetLoss returns the elastic net objective given the residuals with
+// the weights of their rows and the coefficients along with the weights of
+// the l1 and l2 penalties
+func elasticNetLoss(residual, W, beta []float64, l1, l2 float64) float64 {
+	var loss float64
+	n := sumWeights(W, len(residual))
+	for i, r := range residual {
+		loss += weightAt(W, i) * r * r / (2 * n)
+	}
+	for _, b := range beta {
+		loss += l1*math.Abs(b) + l2/2*b*b
+	}
+	return loss
+}
+
+// ridgePenalty is half the mean squared error plus Alpha/2 times the squared
+// norm of the coefficients. The penalty adds Alpha to the diagonal of the
+// hessian, which also keeps it positive definite when columns are collinear
+type ridgePenalty struct {
+	leastSquares
+	Alpha float64
+}
+
+// Value implements objective
+func (o ridgePenalty) Value(theta []float64) float64 {
+	loss := o.leastSquares.Value(theta) / 2
+	for _, b := range theta[1:] {
+		loss += o.Alpha / 2 * b * b
+	}
+	return loss
+}
+
+// Gradient implements objective
+func (o ridgePenalty) Gradient(theta []float64) []float64 {
+	grad := o.leastSquares.Gradient(theta)
+	for j := range grad {
+		grad[j] /= 2
+		if j > 0 {
+			grad[j] += o.Alpha * theta[j]
+		}
+	}
+	return grad
+}
+
+// Hessian implements objective
+func (o ridgePenalty) Hessian(theta []float64) [][]float64 {
+	hess := o.leastSquares.Hessian(theta)
+	for j := range hess {
+		for l := range hess[j] {
+			hess[j][l] /= 2
+		}
+		if j > 0 {
+			hess[j][j] += o.Alpha
+		}
+	}
+	return hess
+}
+
+// maxAlpha returns the smallest alpha at which the elastic net with the given
+// l1 ratio sets every coefficient of the standardized columns Z to zero. Ridge
+// never zeroes them so it borrows the value of an l1 ratio of 0.001 like
+// glmnet does to start its path
+func maxAlpha(Z [][]float64, Y []float64, l1Ratio float64) float64 {
+	n := float64(len(Z))
+	var mean float64
+	for _, y := range Y {
+		mean += y / n
+	}
+	var max float64
+	for j := range Z[0] {
+		var c float64
+		for i := range Z {
+			c += Z[i][j] * (Y[i] - mean) / n
+		}
+		max = math.Max(max, math.Abs(c))
+	}
+	return max / math.Max(l1Ratio, 1e-3)
+}
+
+// alphaGrid returns n alphas spaced evenly on a log scale from max down to
+// max*minRatio
+func alphaGrid(max, minRatio float64, n int) []float64 {
+	alphas := make([]float64, n)
+	for i := range alphas {
+		t := 0.0
+		if n > 1 {
+			t = float64(i) / float64(n-1)
+		}
+		alphas[i] = max * math.Pow(minRatio, t)
+	}
+	return alphas
+}
+
+// pathPoint is the fit of the elastic net at one alpha along a path
+type pathPoint struct {
+	Alpha float64
+	// Standardized are the coefficients of the standardized columns, which
+	// are on a comparable scale for plotting
+	Standardized []float64
+	Coef         []float64
+	Intercept    float64
+}
+
+// regularizationPath fits the elastic net of the solver at each alpha in turn,
+// warm starting coordinate descent from the coefficients of the previous
+// alpha. The alphas are expected in decreasing order
+func regularizationPath(s regularizedSolver, X [][]float64, Y []float64, alphas []float64) ([]pathPoint, error) {
+	if err := checkFinite(X, Y); err != nil {
+		return nil, err
+	}
+	sc, err := newScaler(X, nil)
+	if err != nil {
+		return nil, err
+	}
+	Z := sc.transform(X)
+	beta := make([]float64, len(X[0]))
+	path := make([]pathPoint, len(alphas))
+	for i, alpha := range alphas {
+		s.Alpha = alpha
+		var theta []float64
+		if s.L1Ratio == 0 {
+			obj := ridgePenalty{leastSquares: leastSquares{X: Z, Y: Y}, Alpha: alpha}
+			theta, _, err = minimizeNewton(obj, append([]float64{0}, beta...), s.Epsilon, s.MaxIter, s.LineSearch)
+		} else {
+			theta, _, err = s.coordinateDescent(Z, Y, nil, beta)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("alpha %g: %v", alpha, err)
+		}
+		beta = theta[1:]
+		orig := sc.unscale(theta)
+		path[i] = pathPoint{Alpha: alpha, Standardized: append([]float64{}, beta...), Coef: orig[1:], Intercept: orig[0]}
+	}
+	return path, nil
+}
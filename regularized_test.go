@@ -0,0 +1,84 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSoftThreshold(t *testing.T) {
+	tests := []struct {
+		x, t float64
+		want float64
+	}{
+		{3, 1, 2},
+		{-3, 1, -2},
+		{0.5, 1, 0},
+		{-1, 1, 0},
+		{1, 1, 0},
+		{2, 0, 2},
+	}
+	for _, tt := range tests {
+		if got := softThreshold(tt.x, tt.t); got != tt.want {
+			t.Errorf("softThreshold(%g, %g) = %g, want %g", tt.x, tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestRegularizedOrthogonal(t *testing.T) {
+	// the columns standardize to the orthogonal (-1, 1, -1, 1) and
+	// (1, 1, -1, -1), and y = 2 + 3 z1 + 0.5 z2, so each standardized
+	// coefficient of the elastic net is the soft thresholded least squares one
+	// divided by 1 + Alpha(1-L1Ratio)
+	X := [][]float64{{0, 4}, {2, 4}, {0, 2}, {2, 2}}
+	Y := []float64{-0.5, 5.5, -1.5, 4.5}
+	tests := []struct {
+		alpha, l1Ratio float64
+		want           []float64
+	}{
+		{0, 1, []float64{-2.5, 3, 0.5}},
+		{0.25, 1, []float64{-1.5, 2.75, 0.25}},
+		{1, 1, []float64{0, 2, 0}},
+		{1, 0.5, []float64{1.0 / 3, 5.0 / 3, 0}},
+		{1, 0, []float64{-0.25, 1.5, 0.25}},
+	}
+	for _, tt := range tests {
+		s := regularizedSolver{Alpha: tt.alpha, L1Ratio: tt.l1Ratio, Epsilon: 1e-12, MaxIter: 100, LineSearch: armijoLineSearch}
+		result, err := s.Fit(X, Y)
+		if err != nil {
+			t.Fatalf("alpha %g l1 ratio %g: %v", tt.alpha, tt.l1Ratio, err)
+		}
+		got := append([]float64{result.Intercept}, result.Coef...)
+		for j := range tt.want {
+			if math.Abs(got[j]-tt.want[j]) > 1e-9 {
+				t.Errorf("alpha %g l1 ratio %g: parameters %v, want %v", tt.alpha, tt.l1Ratio, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestLassoWithoutPenaltyMatchesQR(t *testing.T) {
+	X, Y := readTestCSV(t, "advertising.csv", []string{"TV", "Radio", "Newspaper"}, "Sales")
+	W := make([]float64, len(Y))
+	for i := range W {
+		W[i] = float64(1 + i%3)
+	}
+	for _, w := range [][]float64{nil, W} {
+		coef, b, _, err := qrRegression(X, Y, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lasso, err := regularizedSolver{L1Ratio: 1, Epsilon: 1e-12, MaxIter: 10000}.FitWeighted(X, Y, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(lasso.Intercept-b) > 1e-8 {
+			t.Errorf("weighted %t: lasso intercept %.12g, qr intercept %.12g", w != nil, lasso.Intercept, b)
+		}
+		for j := range coef {
+			if math.Abs(lasso.Coef[j]-coef[j]) > 1e-8 {
+				t.Errorf("weighted %t: lasso coefficient %d %.12g, qr %.12g", w != nil, j, lasso.Coef[j], coef[j])
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// resampledInference is the inference for the parameters of a fit taken from
+// refitting it to resampled rows rather than from the least squares formulas,
+// which do not hold for every solver
+type resampledInference struct {
+	// Method is bootstrap or jackknife
+	Method string `json:"method"`
+	// Resamples is the number of refits that succeeded
+	Resamples    int                `json:"resamples"`
+	Coefficients []CoefficientStats `json:"coefficients"`
+}
+
+// checkResample returns an error unless method names a resampling method or
+// is none
+func checkResample(method string, resamples int) error {
+	switch method {
+	case "none", "jackknife":
+		return nil
+	case "bootstrap":
+		if resamples < 2 {
+			return fmt.Errorf("the bootstrap needs at least two resamples, got %d", resamples)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown resampling method %q, must be none, bootstrap or jackknife", method)
+}
+
+// resampleInference refits the solver to resampled rows of X, Y and the row
+// weights W, which may be nil, and returns the inference of the intercept
+// followed by each coefficient at the given level. The bootstrap draws
+// resamples sets of rows with replacement using seed and takes the standard
+// deviation of the refits as the standard error and their percentiles as the
+// interval. The jackknife leaves out each row in turn, scaling the spread of
+// the refits up to a standard error and using a t interval about the estimate.
+// Refits that fail are left out of both
+func resampleInference(solver Solver, X [][]float64, Y, W []float64, coef []float64, b float64, names []string, method string, resamples int, seed int64, level float64) (*resampledInference, error) {
+	n := len(X)
+	var sets [][]int
+	switch method {
+	case "bootstrap":
+		rng := rand.New(rand.NewSource(seed))
+		for r := 0; r < resamples; r++ {
+			rows := make([]int, n)
+			for i := range rows {
+				rows[i] = rng.Intn(n)
+			}
+			sets = append(sets, rows)
+		}
+	case "jackknife":
+		for left := 0; left < n; left++ {
+			rows := make([]int, 0, n-1)
+			for i := 0; i < n; i++ {
+				if i != left {
+					rows = append(rows, i)
+				}
+			}
+			sets = append(sets, rows)
+		}
+	default:
+		return nil, fmt.Errorf("unknown resampling method %q", method)
+	}
+	// refits holds the intercept followed by the coefficients of each refit
+	var refits [][]float64
+	for _, rows := range sets {
+		subX, subY := selectRows(X, Y, rows)
+		var result *Result
+		var err error
+		if W == nil {
+			result, err = solver.Fit(subX, subY)
+		} else if ws, ok := solver.(weightedSolver); ok {
+			subW := make([]float64, len(rows))
+			for i, row := range rows {
+				subW[i] = W[row]
+			}
+			result, err = ws.FitWeighted(subX, subY, subW)
+		} else {
+			return nil, fmt.Errorf("the solver does not support row weights")
+		}
+		if err != nil {
+			continue
+		}
+		refits = append(refits, append([]float64{result.Intercept}, result.Coef...))
+	}
+	if len(refits) < 2 {
+		return nil, fmt.Errorf("only %d of the %d %s refits succeeded", len(refits), len(sets), method)
+	}
+
+	theta := append([]float64{b}, coef...)
+	names = append([]string{"Intercept"}, names...)
+	df := float64(weightedRows(W, n) - len(theta))
+	if method == "jackknife" {
+		df = float64(len(refits) - 1)
+	}
+	if df < 1 {
+		return nil, fmt.Errorf("the %s leaves %g degrees of freedom for its intervals and p-values, %d weighted rows for %d parameters", method, df, weightedRows(W, n), len(theta))
+	}
+	tCrit := studentTQuantile(1-(1-level)/2, df)
+	stats := make([]CoefficientStats, len(theta))
+	for j := range theta {
+		vals := make([]float64, len(refits))
+		for r := range refits {
+			vals[r] = refits[r][j]
+		}
+		_, sd := meanSD(vals)
+		s := CoefficientStats{Name: names[j], Estimate: theta[j]}
+		if method == "jackknife" {
+			m := float64(len(vals))
+			s.StdErr = sd * (m - 1) / math.Sqrt(m)
+			s.Lower, s.Upper = theta[j]-tCrit*s.StdErr, theta[j]+tCrit*s.StdErr
+		} else {
+			s.StdErr = sd
+			sort.Float64s(vals)
+			s.Lower, s.Upper = percentile(vals, (1-level)/2), percentile(vals, 1-(1-level)/2)
+		}
+		s.TStat = theta[j] / s.StdErr
+		s.PValue = 2 * studentTCDF(-math.Abs(s.TStat), df)
+		stats[j] = s
+	}
+	return &resampledInference{Method: method, Resamples: len(refits), Coefficients: stats}, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+// meanSolver fits the mean of Y alone, whose jackknife standard error is the
+// textbook s/sqrt(n)
+type meanSolver struct{}
+
+// Fit implements Solver
+func (meanSolver) Fit(X [][]float64, Y []float64) (*Result, error) {
+	var mean float64
+	for _, y := range Y {
+		mean += y / float64(len(Y))
+	}
+	return &Result{Intercept: mean}, nil
+}
+
+// failingSolver fails every fit
+type failingSolver struct{}
+
+// Fit implements Solver
+func (failingSolver) Fit(X [][]float64, Y []float64) (*Result, error) {
+	return nil, errors.New("no fit")
+}
+
+func TestResampleInference(t *testing.T) {
+	// the mean is 5, the sample standard deviation sqrt(32/7) and the
+	// population one 2
+	Y := []float64{2, 4, 4, 4, 5, 5, 7, 9}
+	X := make([][]float64, len(Y))
+	for i := range X {
+		X[i] = []float64{float64(i)}
+	}
+	jack, err := resampleInference(meanSolver{}, X, Y, nil, nil, 5, nil, "jackknife", 0, 1, 0.95)
+	if err != nil {
+		t.Fatal(err)
+	}
+	se := math.Sqrt(32.0/7) / math.Sqrt(8)
+	half := studentTQuantile(0.975, 7) * se
+	got := jack.Coefficients[0]
+	if jack.Resamples != 8 || math.Abs(got.StdErr-se) > 1e-12 || math.Abs(got.Lower-(5-half)) > 1e-12 || math.Abs(got.Upper-(5+half)) > 1e-12 {
+		t.Errorf("jackknife %d refits, std err %.12g in [%.12g, %.12g], want 8, %.12g in [%.12g, %.12g]",
+			jack.Resamples, got.StdErr, got.Lower, got.Upper, se, 5-half, 5+half)
+	}
+
+	// the bootstrap standard error of a mean tends to the population
+	// standard deviation over sqrt(n)
+	boot, err := resampleInference(meanSolver{}, X, Y, nil, nil, 5, nil, "bootstrap", 4000, 1, 0.95)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = boot.Coefficients[0]
+	if want := 2 / math.Sqrt(8); math.Abs(got.StdErr-want) > 0.05*want {
+		t.Errorf("bootstrap std err %.6g, want about %.6g", got.StdErr, want)
+	}
+	if !(2 <= got.Lower && got.Lower < 5 && 5 < got.Upper && got.Upper <= 9) {
+		t.Errorf("bootstrap interval [%g, %g] does not bracket the mean within the data", got.Lower, got.Upper)
+	}
+	again, err := resampleInference(meanSolver{}, X, Y, nil, nil, 5, nil, "bootstrap", 4000, 1, 0.95)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Coefficients[0] != got {
+		t.Errorf("the same seed gave %+v then %+v", got, again.Coefficients[0])
+	}
+
+	// refits of rows on an exact line never move
+	exact := []float64{1, 3, 5, 7, 9, 11, 13, 15}
+	line, err := resampleInference(qrSolver{}, X, exact, nil, []float64{2}, 1, []string{"x"}, "jackknife", 0, 1, 0.95)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range line.Coefficients {
+		if c.StdErr > 1e-12 {
+			t.Errorf("%s has std err %g on an exact line, want zero", c.Name, c.StdErr)
+		}
+	}
+
+	if _, err := resampleInference(failingSolver{}, X, Y, nil, nil, 5, nil, "jackknife", 0, 1, 0.95); err == nil {
+		t.Error("every refit failing gave no error")
+	}
+
+	// a single row leaves the bootstrap no degrees of freedom for its t
+	// quantiles, which would otherwise give NaN intervals and p-values
+	if _, err := resampleInference(meanSolver{}, X[:1], Y[:1], nil, nil, 2, nil, "bootstrap", 100, 1, 0.95); err == nil || !strings.Contains(err.Error(), "degrees of freedom") {
+		t.Errorf("a bootstrap without degrees of freedom gave %v", err)
+	}
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// theilSenExactRows is the number of rows up to which the theil-sen slope is
+// the median of every pairwise slope computed directly
+const theilSenExactRows = 500
+
+// checkSinglePredictor returns an error unless X holds a single predictor,
+// which the estimators that work with lines through pairs of points need
+func checkSinglePredictor(name string, X [][]float64) error {
+	if len(X[0]) != 1 {
+		return fmt.Errorf("the %s solver fits a single predictor but %d were given", name, len(X[0]))
+	}
+	return nil
+}
+
+// theilSenSolver fits the slope as the median of the slopes of the lines
+// through every pair of rows with distinct x, and the intercept as the median
+// of y minus the slope times x. Its breakdown point is about 29%, the share of
+// rows that can be arbitrarily bad before the slope can be dragged anywhere
+type theilSenSolver struct{}
+
+// Fit implements Solver
+func (theilSenSolver) Fit(X [][]float64, Y []float64) (*Result, error) {
+	if err := checkSinglePredictor("theil-sen", X); err != nil {
+		return nil, err
+	}
+	if err := checkFinite(X, Y); err != nil {
+		return nil, err
+	}
+	x := column(X, 0)
+	slope, err := medianSlope(x, Y)
+	if err != nil {
+		return nil, err
+	}
+	intercepts := make([]float64, len(x))
+	for i := range x {
+		intercepts[i] = Y[i] - slope*x[i]
+	}
+	return &Result{Coef: []float64{slope}, Intercept: median(intercepts)}, nil
+}
+
+// point is a single observation of a predictor and the response
+type point struct{ X, Y float64 }
+
+// medianSlope returns the median of the slopes between every pair of points
+// with distinct x. Small inputs list every slope, larger ones bisect on the
+// slope value counting the slopes below it in O(n log n) each time, so the
+// whole search is O(n log n)
+func medianSlope(x, y []float64) (float64, error) {
+	pts := make([]point, len(x))
+	for i := range x {
+		pts[i] = point{x[i], y[i]}
+	}
+	sort.Slice(pts, func(i, j int) bool {
+		if pts[i].X != pts[j].X {
+			return pts[i].X < pts[j].X
+		}
+		return pts[i].Y < pts[j].Y
+	})
+	// pairs counts the pairs of points with distinct x, which are those with
+	// a slope
+	n := len(pts)
+	pairs := n * (n - 1) / 2
+	for i := 0; i < n; {
+		j := i
+		for j < n && pts[j].X == pts[i].X {
+			j++
+		}
+		pairs -= (j - i) * (j - i - 1) / 2
+		i = j
+	}
+	if pairs == 0 {
+		return 0, &SolverError{Err: ErrRankDeficient, Iteration: -1, Detail: "every row has the same x so no slope is defined"}
+	}
+	if n <= theilSenExactRows {
+		var slopes []float64
+		for i := range pts {
+			for j := i + 1; j < n; j++ {
+				if pts[j].X != pts[i].X {
+					slopes = append(slopes, (pts[j].Y-pts[i].Y)/(pts[j].X-pts[i].X))
+				}
+			}
+		}
+		return median(slopes), nil
+	}
+	if pairs%2 == 1 {
+		return kthSlope(pts, pairs/2), nil
+	}
+	return (kthSlope(pts, pairs/2-1) + kthSlope(pts, pairs/2)) / 2, nil
+}
+
+// kthSlope returns the k-th smallest slope, counting from zero, between the
+// points sorted by x and then y. It bisects on the ordering of the float64
+// values between bounds on every slope, keeping lo at or below the k-th slope
+// and hi above it, so it takes at most 64 counts to narrow them to adjacent
+// floats
+func kthSlope(pts []point, k int) float64 {
+	minY, maxY := pts[0].Y, pts[0].Y
+	gap := math.Inf(1)
+	for i, p := range pts {
+		minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+		if i > 0 && p.X != pts[i-1].X {
+			gap = math.Min(gap, p.X-pts[i-1].X)
+		}
+	}
+	bound := (maxY - minY) / gap
+	lo, hi := orderedBits(-bound), orderedBits(math.Nextafter(bound, math.Inf(1)))
+	u := make([]float64, len(pts))
+	buf := make([]float64, len(pts))
+	for {
+		mid := lo>>1 + hi>>1 + lo&hi&1
+		if mid == lo {
+			return orderedFloat(lo)
+		}
+		if slopesBelow(pts, orderedFloat(mid), u, buf) <= k {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+}
+
+// slopesBelow returns the number of pairs of points with a slope below t. The
+// slope of points i before j in x is below t exactly when y - t*x of j is below
+// that of i, so these are the inversions of y - t*x in the order of the points,
+// which merge sort counts. Points with the same x are sorted by y and never
+// count. u and buf are scratch space the length of pts
+func slopesBelow(pts []point, t float64, u, buf []float64) int {
+	for i, p := range pts {
+		u[i] = p.Y - t*p.X
+	}
+	return countInversions(u, buf)
+}
+
+// countInversions sorts v by merge sort and returns the number of pairs that
+// were strictly out of order, using buf as scratch space
+func countInversions(v, buf []float64) int {
+	if len(v) < 2 {
+		return 0
+	}
+	mid := len(v) / 2
+	count := countInversions(v[:mid], buf[:mid]) + countInversions(v[mid:], buf[mid:])
+	i, j := 0, mid
+	for out := range buf[:len(v)] {
+		if j == len(v) || (i < mid && v[i] <= v[j]) {
+			buf[out] = v[i]
+			i++
+		} else {
+			// every value left in the first half is above v[j]
+			count += mid - i
+			buf[out] = v[j]
+			j++
+		}
+	}
+	copy(v, buf[:len(v)])
+	return count
+}
+
+// orderedBits maps a float64 to an int64 with the same ordering, so the
+// midpoint of two of them lies between the floats in the order of their values
+func orderedBits(f float64) int64 {
+	b := int64(math.Float64bits(f))
+	if b < 0 {
+		b = math.MinInt64 - b
+	}
+	return b
+}
+
+// orderedFloat is the inverse of orderedBits
+func orderedFloat(b int64) float64 {
+	if b < 0 {
+		b = math.MinInt64 - b
+	}
+	return math.Float64frombits(uint64(b))
+}
+
+// ransacSolver fits a line by random sample consensus. Each of MaxTrials trials
+// draws two rows with distinct x using Seed and counts the inliers, the rows
+// whose residual from the line through them is at most Threshold. The line
+// with the most inliers wins, ties going to the smaller sum of squared inlier
+// residuals, and the result is least squares on its inliers. A Threshold of
+// zero uses ransacThreshold
+type ransacSolver struct {
+	Threshold float64
+	MaxTrials int
+	Seed      int64
+}
+
+// Fit implements Solver. The inliers of the result are those of the winning
+// trial and Scale is the threshold used
+func (s ransacSolver) Fit(X [][]float64, Y []float64) (*Result, error) {
+	if err := checkSinglePredictor("ransac", X); err != nil {
+		return nil, err
+	}
+	if err := checkFinite(X, Y); err != nil {
+		return nil, err
+	}
+	if s.Threshold < 0 {
+		return nil, fmt.Errorf("ransac threshold must not be negative, got %g", s.Threshold)
+	}
+	n := len(X)
+	if n < 2 {
+		return nil, fmt.Errorf("ransac needs at least two rows, got %d", n)
+	}
+	threshold := s.Threshold
+	if threshold == 0 {
+		var err error
+		if threshold, err = ransacThreshold(X, Y); err != nil {
+			return nil, err
+		}
+	}
+	rng := rand.New(rand.NewSource(s.Seed))
+	inliers, best := make([]bool, n), make([]bool, n)
+	bestCount, bestSSE := 0, math.Inf(1)
+	for trial := 0; trial < s.MaxTrials; trial++ {
+		i, j := rng.Intn(n), rng.Intn(n-1)
+		if j >= i {
+			j++
+		}
+		if X[i][0] == X[j][0] {
+			continue
+		}
+		slope := (Y[j] - Y[i]) / (X[j][0] - X[i][0])
+		b := Y[i] - slope*X[i][0]
+		count, sse := 0, 0.0
+		for row := range X {
+			r := Y[row] - slope*X[row][0] - b
+			inliers[row] = math.Abs(r) <= threshold
+			if inliers[row] {
+				count++
+				sse += r * r
+			}
+		}
+		if count > bestCount || (count == bestCount && sse < bestSSE) {
+			bestCount, bestSSE = count, sse
+			inliers, best = best, inliers
+		}
+	}
+	if bestCount < 2 {
+		return nil, &SolverError{
+			Err:       ErrNoConsensus,
+			Iteration: s.MaxTrials,
+			Detail:    fmt.Sprintf("no line had two inliers within %g", threshold),
+		}
+	}
+	subX, subY, _ := inlierRows(best, X, Y, nil)
+	coef, b, cond, err := qrRegression(subX, subY, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Coef: coef, Intercept: b, Condition: cond, Inliers: best, Scale: threshold}, nil
+}
+
+// ransacScales is the number of robust standard deviations of the residuals
+// within which the default ransac threshold counts a row as an inlier, wide
+// enough to keep about 99% of rows with normal errors
+const ransacScales = 2.5
+
+// ransacThreshold returns the default ransac threshold, ransacScales times the
+// MAD scale of the residuals from a theil-sen fit. The MAD is zero, or down at
+// rounding error, when more than half the rows lie on that line, so the
+// threshold is at least a tiny fraction of the largest response, or of one if
+// that is smaller, which keeps those rows inliers of the line through any two
+func ransacThreshold(X [][]float64, Y []float64) (float64, error) {
+	fit, err := theilSenSolver{}.Fit(X, Y)
+	if err != nil {
+		return 0, err
+	}
+	residuals := make([]float64, len(Y))
+	maxY := 1.0
+	for i := range Y {
+		residuals[i] = Y[i] - fit.Coef[0]*X[i][0] - fit.Intercept
+		maxY = math.Max(maxY, math.Abs(Y[i]))
+	}
+	return math.Max(ransacScales*madScale(residuals), 1e-9*maxY), nil
+}
+
+// inlierWeights returns a weight of one for each inlier and zero for each
+// outlier
+func inlierWeights(inliers []bool) []float64 {
+	weights := make([]float64, len(inliers))
+	for i, in := range inliers {
+		if in {
+			weights[i] = 1
+		}
+	}
+	return weights
+}
+
+// inlierIndices returns the indices of the rows that are inliers
+func inlierIndices(inliers []bool) []int {
+	var rows []int
+	for i, in := range inliers {
+		if in {
+			rows = append(rows, i)
+		}
+	}
+	return rows
+}
+
+// inlierRowNumbers returns the rowNumber of each inlier, which labels the rows
+// of the inlier subset by the rows of the data they came from
+func inlierRowNumbers(inliers []bool) []int {
+	rows := inlierIndices(inliers)
+	for k, i := range rows {
+		rows[k] = rowNumber(i)
+	}
+	return rows
+}
+
+// inlierRows returns the rows of X, Y and the row weights W, which may be nil,
+// that are inliers
+func inlierRows(inliers []bool, X [][]float64, Y, W []float64) ([][]float64, []float64, []float64) {
+	rows := inlierIndices(inliers)
+	subX, subY := selectRows(X, Y, rows)
+	if W == nil {
+		return subX, subY, nil
+	}
+	subW := make([]float64, len(rows))
+	for i, row := range rows {
+		subW[i] = W[row]
+	}
+	return subX, subY, subW
+}
+
+// writeInliers writes the inlier threshold and number of inliers of a
+// consensus fit to w, followed by the outliers with the largest residuals.
+// Rows are numbered by rowNumber as in the diagnostics plots
+func writeInliers(w io.Writer, inliers []bool, Y, yPred []float64, threshold float64) {
+	const shown = 5
+	var outliers []int
+	for i, in := range inliers {
+		if !in {
+			outliers = append(outliers, i)
+		}
+	}
+	residual := func(i int) float64 { return math.Abs(Y[i] - yPred[i]) }
+	sort.SliceStable(outliers, func(a, b int) bool { return residual(outliers[a]) > residual(outliers[b]) })
+	fmt.Fprintf(w, "Inlier Threshold: %.8g\n", threshold)
+	fmt.Fprintf(w, "Inliers: %d of %d\n", len(inliers)-len(outliers), len(inliers))
+	if len(outliers) > 0 {
+		fmt.Fprintln(w, "Largest Outliers:")
+	}
+	if len(outliers) > shown {
+		outliers = outliers[:shown]
+	}
+	for _, i := range outliers {
+		fmt.Fprintf(w, "\trow %d: residual %.8f\n", rowNumber(i), Y[i]-yPred[i])
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestCountInversions(t *testing.T) {
+	tests := []struct {
+		v    []float64
+		want int
+	}{
+		{[]float64{1, 2, 3}, 0},
+		{[]float64{3, 2, 1}, 3},
+		{[]float64{1, 3, 2, 4, 0}, 5},
+		// equal values are not out of order
+		{[]float64{2, 2, 1, 1}, 4},
+		{[]float64{5, 5, 5}, 0},
+		{[]float64{7}, 0},
+	}
+	for _, tt := range tests {
+		v := append([]float64{}, tt.v...)
+		if got := countInversions(v, make([]float64, len(v))); got != tt.want {
+			t.Errorf("countInversions(%v) = %d, want %d", tt.v, got, tt.want)
+		}
+		if !sort.Float64sAreSorted(v) {
+			t.Errorf("countInversions(%v) left %v unsorted", tt.v, v)
+		}
+	}
+}
+
+func TestMedianSlopeMatchesPairwise(t *testing.T) {
+	// above theilSenExactRows the slopes are counted rather than listed, so
+	// compare the counted order statistics with those of every pairwise slope.
+	// Rounding x and y gives repeated x, which have no slope, and tied slopes
+	rng := rand.New(rand.NewSource(1))
+	for _, n := range []int{theilSenExactRows + 1, theilSenExactRows + 100} {
+		x, y := make([]float64, n), make([]float64, n)
+		for i := range x {
+			x[i] = math.Round(rng.Float64()*200) / 4
+			y[i] = math.Round(3*x[i] + 10*rng.NormFloat64())
+		}
+		pts := make([]point, n)
+		for i := range x {
+			pts[i] = point{x[i], y[i]}
+		}
+		sort.Slice(pts, func(i, j int) bool {
+			if pts[i].X != pts[j].X {
+				return pts[i].X < pts[j].X
+			}
+			return pts[i].Y < pts[j].Y
+		})
+		var slopes []float64
+		for i := range pts {
+			for j := i + 1; j < n; j++ {
+				if pts[j].X != pts[i].X {
+					slopes = append(slopes, (pts[j].Y-pts[i].Y)/(pts[j].X-pts[i].X))
+				}
+			}
+		}
+		sort.Float64s(slopes)
+		for _, k := range []int{0, 1, len(slopes) / 3, len(slopes) / 2, len(slopes) - 1} {
+			if got := kthSlope(pts, k); math.Abs(got-slopes[k]) > 1e-12*math.Max(1, math.Abs(slopes[k])) {
+				t.Errorf("n %d: kthSlope(%d) = %.15g, want %.15g", n, k, got, slopes[k])
+			}
+		}
+		got, err := medianSlope(x, y)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := median(slopes); math.Abs(got-want) > 1e-12*math.Max(1, math.Abs(want)) {
+			t.Errorf("n %d: medianSlope = %.15g, want %.15g", n, got, want)
+		}
+	}
+}
+
+func TestTheilSen(t *testing.T) {
+	// two of the nine rows are far off y = 1 + 2x, below the breakdown point
+	X := [][]float64{{1}, {2}, {3}, {4}, {5}, {6}, {7}, {8}, {9}}
+	Y := []float64{3, 5, 7, 9, 11, 13, 15, 100, -50}
+	result, err := theilSenSolver{}.Fit(X, Y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Intercept != 1 || result.Coef[0] != 2 {
+		t.Errorf("line %g + %gx, want 1 + 2x", result.Intercept, result.Coef[0])
+	}
+	_, err = theilSenSolver{}.Fit([][]float64{{1}, {1}, {1}}, []float64{1, 2, 3})
+	if serr, ok := err.(*SolverError); !ok || serr.Err != ErrRankDeficient {
+		t.Errorf("a single x gave error %v, want rank deficient", err)
+	}
+}
+
+func TestRansac(t *testing.T) {
+	tests := []struct {
+		name    string
+		X       [][]float64
+		Y       []float64
+		b, m    float64
+		inliers int
+	}{
+		{
+			"outliers",
+			[][]float64{{1}, {2}, {3}, {4}, {5}, {6}, {7}, {8}, {9}, {10}},
+			[]float64{3.1, 4.9, 7.05, 9, 10.95, 13.1, 15, 40, 17, -20},
+			1, 2, 7,
+		},
+		// more than half the rows lie on y = 3x, where the residuals of the
+		// line through two of them are rounding error, so the default
+		// threshold must be above zero
+		{
+			"exact",
+			[][]float64{{0.1}, {0.2}, {0.3}, {0.7}, {0.9}, {1.1}, {1.3}},
+			[]float64{0.3, 0.6, 0.9, 2.1, 2.7, 9, -4},
+			0, 3, 5,
+		},
+		{
+			"constant",
+			[][]float64{{1}, {2}, {3}, {4}},
+			[]float64{5, 5, 5, 5},
+			5, 0, 4,
+		},
+	}
+	for _, tt := range tests {
+		result, err := ransacSolver{MaxTrials: 100, Seed: 1}.Fit(tt.X, tt.Y)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if math.Abs(result.Intercept-tt.b) > 0.1 || math.Abs(result.Coef[0]-tt.m) > 0.05 {
+			t.Errorf("%s: line %.6g + %.6gx, want %g + %gx", tt.name, result.Intercept, result.Coef[0], tt.b, tt.m)
+		}
+		count := 0
+		for _, in := range result.Inliers {
+			if in {
+				count++
+			}
+		}
+		if count != tt.inliers || result.Scale <= 0 {
+			t.Errorf("%s: %d inliers within %g, want %d within a positive threshold", tt.name, count, result.Scale, tt.inliers)
+		}
+	}
+}
+
+func TestInlierRowNumbers(t *testing.T) {
+	// rows 8 and 10 of the data are outliers
+	X := [][]float64{{1}, {2}, {3}, {4}, {5}, {6}, {7}, {8}, {9}, {10}}
+	Y := []float64{3.1, 4.9, 7.05, 9, 10.95, 13.1, 15, 40, 19, -20}
+	inliers := []bool{true, true, true, true, true, true, true, false, true, false}
+	if want := []int{1, 2, 3, 4, 5, 6, 7, 9}; !reflect.DeepEqual(inlierRowNumbers(inliers), want) {
+		t.Errorf("inliers are rows %v, want %v", inlierRowNumbers(inliers), want)
+	}
+
+	// the diagnostics of the inlier subset label its rows by those of the data
+	subX, subY, _ := inlierRows(inliers, X, Y, nil)
+	coef, b, _, err := qrRegression(subX, subY, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary := newFitSummary(subX, subY, nil, coef, b, []string{"x", "y"}, .95)
+	d := newDiagnostics(subX, subY, nil, coef, b, summary.Covariance, summary.ResidualSE*summary.ResidualSE)
+	d.Rows = inlierRowNumbers(inliers)
+	if d.Rows[7] != 9 || subY[7] != Y[8] {
+		t.Errorf("the last inlier is labelled row %d, want 9", d.Rows[7])
+	}
+
+	yPred := make([]float64, len(X))
+	for i := range X {
+		yPred[i] = predict(coef, X[i], b)
+	}
+	var out strings.Builder
+	writeInliers(&out, inliers, Y, yPred, 1)
+	report := out.String()
+	first, second := strings.Index(report, "\trow 10: "), strings.Index(report, "\trow 8: ")
+	if first < 0 || second < first {
+		t.Errorf("outliers are not reported as rows 10 and 8 by residual:\n%s", report)
+	}
+}
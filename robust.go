@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// robustLoss is the loss of an M-estimator, which grows more slowly than the
+// squared error for large residuals so outliers pull less on the fit
+type robustLoss int
+
+const (
+	// noRobustLoss fits ordinary least squares
+	noRobustLoss robustLoss = iota
+	// huberLoss is quadratic for residuals within the threshold and linear
+	// beyond it
+	huberLoss
+	// bisquareLoss is Tukey's biweight, which stops growing at the threshold
+	// so residuals beyond it get no weight at all
+	bisquareLoss
+)
+
+// parseRobustLoss returns the loss named on the command line
+func parseRobustLoss(s string) (robustLoss, error) {
+	switch s {
+	case "none":
+		return noRobustLoss, nil
+	case "huber":
+		return huberLoss, nil
+	case "bisquare":
+		return bisquareLoss, nil
+	}
+	return noRobustLoss, fmt.Errorf("unknown robust loss %q, must be none, huber or bisquare", s)
+}
+
+// String returns the command line name of the loss
+func (l robustLoss) String() string {
+	switch l {
+	case huberLoss:
+		return "huber"
+	case bisquareLoss:
+		return "bisquare"
+	}
+	return "none"
+}
+
+// defaultThreshold returns the threshold, in units of the residual scale, that
+// gives 95% efficiency at the normal distribution
+func (l robustLoss) defaultThreshold() float64 {
+	if l == bisquareLoss {
+		return 4.685
+	}
+	return 1.345
+}
+
+// rho returns the loss of the scaled residual u with threshold c
+func (l robustLoss) rho(u, c float64) float64 {
+	a := math.Abs(u)
+	switch l {
+	case huberLoss:
+		if a <= c {
+			return u * u / 2
+		}
+		return c*a - c*c/2
+	case bisquareLoss:
+		if a <= c {
+			t := 1 - (u/c)*(u/c)
+			return c * c / 6 * (1 - t*t*t)
+		}
+		return c * c / 6
+	}
+	return u * u / 2
+}
+
+// weight returns the weight iteratively reweighted least squares gives a row
+// with scaled residual u and threshold c, which is rho'(u)/u
+func (l robustLoss) weight(u, c float64) float64 {
+	a := math.Abs(u)
+	switch l {
+	case huberLoss:
+		if a <= c {
+			return 1
+		}
+		return c / a
+	case bisquareLoss:
+		if a < c {
+			t := 1 - (u/c)*(u/c)
+			return t * t
+		}
+		return 0
+	}
+	return 1
+}
+
+// madScale returns the median absolute deviation of the residuals about their
+// median, scaled by 1/0.6745 to estimate the standard deviation of normal
+// errors
+func madScale(residuals []float64) float64 {
+	m := median(residuals)
+	dev := make([]float64, len(residuals))
+	for i, r := range residuals {
+		dev[i] = math.Abs(r - m)
+	}
+	return median(dev) / 0.6744897501960817
+}
+
+// robustSolver fits an M-estimator by iteratively reweighted least squares.
+// Starting from the least squares fit, each iteration estimates the scale of
+// the residuals by their MAD, weights every row by the weight of the loss at
+// its residual over Threshold times the scale, and refits weighted least
+// squares with the newton solver warm started from the previous fit. It stops
+// once an iteration moves the parameters less than Epsilon and fails after
+// MaxIter iterations. A Threshold of zero uses the default of the loss
+type robustSolver struct {
+	Loss       robustLoss
+	Threshold  float64
+	Epsilon    float64
+	MaxIter    int
+	LineSearch lineSearch
+}
+
+// Fit implements Solver
+func (s robustSolver) Fit(X [][]float64, Y []float64) (*Result, error) {
+	return s.FitWeighted(X, Y, nil)
+}
+
+// FitWeighted implements weightedSolver, fitting each reweighting with the
+// robust weight of each row multiplied by its weight in W. The weights of the
+// result are the robust weights alone
+func (s robustSolver) FitWeighted(X [][]float64, Y, W []float64) (*Result, error) {
+	if err := checkFinite(X, Y); err != nil {
+		return nil, err
+	}
+	c := s.Threshold
+	if c == 0 {
+		c = s.Loss.defaultThreshold()
+	}
+	if c < 0 {
+		return nil, fmt.Errorf("robust threshold must be positive, got %g", c)
+	}
+	theta, _, err := minimizeNewton(leastSquares{X: X, Y: Y, W: W}, make([]float64, len(X[0])+1), s.Epsilon, s.MaxIter, s.LineSearch)
+	if err != nil {
+		return nil, err
+	}
+	n := len(X)
+	residuals := make([]float64, n)
+	weights, fitWeights := make([]float64, n), make([]float64, n)
+	var scale float64
+	// reweight sets the residuals, scale and weights at theta and returns the
+	// mean loss of the scaled residuals
+	reweight := func() float64 {
+		for i := range X {
+			residuals[i] = Y[i] - predict(theta[1:], X[i], theta[0])
+		}
+		scale = madScale(residuals)
+		var loss float64
+		for i, r := range residuals {
+			u := r / scale
+			if scale == 0 {
+				// more than half of the rows are fitted exactly, the
+				// rest are outliers
+				u = 0
+				if r != 0 {
+					u = math.Inf(1)
+				}
+			}
+			weights[i] = s.Loss.weight(u, c)
+			fitWeights[i] = weights[i] * weightAt(W, i)
+			loss += s.Loss.rho(u, c) / float64(n)
+		}
+		return loss
+	}
+	var trace []Iteration
+	prev := make([]float64, len(theta))
+	reweight()
+	for it := 0; it < s.MaxIter; it++ {
+		copy(prev, theta)
+		if theta, _, err = minimizeNewton(leastSquares{X: X, Y: Y, W: fitWeights}, theta, s.Epsilon, s.MaxIter, s.LineSearch); err != nil {
+			if serr, ok := err.(*SolverError); ok {
+				serr.Detail = fmt.Sprintf("reweighting %d: %s", it, serr.Detail)
+			}
+			return nil, err
+		}
+		for j := range prev {
+			prev[j] = theta[j] - prev[j]
+		}
+		step := norm(prev)
+		trace = append(trace, newIteration(it, step, 1, reweight(), theta))
+		if step < s.Epsilon {
+			return &Result{Coef: theta[1:], Intercept: theta[0], Trace: trace, Weights: weights, Scale: scale}, nil
+		}
+	}
+	return nil, &SolverError{
+		Err:       ErrNotConverged,
+		Iteration: len(trace),
+		Detail:    fmt.Sprintf("robust weights still changing after %d reweightings", len(trace)),
+	}
+}
+
+// combineWeights returns the product of the row weights W, which may be nil,
+// and the robust weights
+func combineWeights(W, robust []float64) []float64 {
+	combined := make([]float64, len(robust))
+	for i, w := range robust {
+		combined[i] = w * weightAt(W, i)
+	}
+	return combined
+}
+
+// writeRobustWeights writes the residual scale of a robust fit and how many
+// rows it weighted below one half to w, followed by the rows with the lowest
+// weights. Rows are numbered by rowNumber as in the diagnostics plots
+func writeRobustWeights(w io.Writer, weights, Y, yPred []float64, scale float64) {
+	const shown = 5
+	order := make([]int, len(weights))
+	low := 0
+	for i := range order {
+		order[i] = i
+		if weights[i] < 0.5 {
+			low++
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool { return weights[order[a]] < weights[order[b]] })
+	if len(order) > shown {
+		order = order[:shown]
+	}
+	fmt.Fprintf(w, "Robust Scale (MAD): %.8f\n", scale)
+	fmt.Fprintf(w, "Rows Weighted Below 0.5: %d of %d\n", low, len(weights))
+	fmt.Fprintln(w, "Lowest Weights:")
+	for _, i := range order {
+		fmt.Fprintf(w, "\trow %d: weight %.4f residual %.8f\n", rowNumber(i), weights[i], Y[i]-yPred[i])
+	}
+	fmt.Fprintln(w)
+}
+
+// saveRobustWeights writes the robust weight and residual of every row of the
+// fit to a csv file, numbered by rowNumber
+func saveRobustWeights(weights, Y []float64, X [][]float64, coef []float64, b float64, fname string) error {
+	f, err := os.Create(fname)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(f)
+	cw.Write([]string{"row", "weight", "residual"})
+	for i := range weights {
+		cw.Write([]string{strconv.Itoa(rowNumber(i)), formatFloat(weights[i]), formatFloat(Y[i] - predict(coef, X[i], b))})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMadScale(t *testing.T) {
+	tests := []struct {
+		residuals []float64
+		want      float64
+	}{
+		// the median absolute deviation of each is one, scaled to a normal
+		// standard deviation
+		{[]float64{1, 2, 3, 4, 100}, 1 / 0.6744897501960817},
+		{[]float64{-4, -3, -2, -1}, 1 / 0.6744897501960817},
+		{[]float64{7, 9, 11, 13, -50}, 2 / 0.6744897501960817},
+		// more than half the residuals agree
+		{[]float64{5, 5, 5, 9}, 0},
+	}
+	for _, tt := range tests {
+		if got := madScale(tt.residuals); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("madScale(%v) = %.12g, want %.12g", tt.residuals, got, tt.want)
+		}
+	}
+}
+
+func TestRobustLossWeight(t *testing.T) {
+	tests := []struct {
+		loss robustLoss
+		u, c float64
+		want float64
+	}{
+		{huberLoss, 0.5, 1.345, 1},
+		{huberLoss, -2.69, 1.345, 0.5},
+		{huberLoss, math.Inf(1), 1.345, 0},
+		{bisquareLoss, 0, 4, 1},
+		{bisquareLoss, 2, 4, 0.5625},
+		{bisquareLoss, -4, 4, 0},
+		{noRobustLoss, 100, 1, 1},
+	}
+	for _, tt := range tests {
+		if got := tt.loss.weight(tt.u, tt.c); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("%s weight(%g, %g) = %g, want %g", tt.loss, tt.u, tt.c, got, tt.want)
+		}
+	}
+}
+
+func TestRobustIgnoresOutlier(t *testing.T) {
+	// nine rows lie on y = 1 + 2x and the last is far above it. As the
+	// reweighting closes in on the line the MAD of the residuals shrinks and
+	// the weight of the outlier vanishes
+	var X [][]float64
+	var Y []float64
+	for i := 1; i <= 10; i++ {
+		X = append(X, []float64{float64(i)})
+		Y = append(Y, 1+2*float64(i))
+	}
+	Y[9] = 100
+	for _, loss := range []robustLoss{huberLoss, bisquareLoss} {
+		result, err := robustSolver{Loss: loss, Epsilon: 1e-10, MaxIter: 100, LineSearch: armijoLineSearch}.Fit(X, Y)
+		if err != nil {
+			t.Fatalf("%s: %v", loss, err)
+		}
+		if math.Abs(result.Intercept-1) > 1e-6 || math.Abs(result.Coef[0]-2) > 1e-6 {
+			t.Errorf("%s: line %.8g + %.8gx, want 1 + 2x", loss, result.Intercept, result.Coef[0])
+		}
+		if result.Weights[9] > 1e-9 {
+			t.Errorf("%s: the outlier has weight %g, want zero", loss, result.Weights[9])
+		}
+	}
+	// without a robust loss a zero row weight is what drops the outlier
+	W := []float64{1, 1, 1, 1, 1, 1, 1, 1, 1, 0}
+	result, err := robustSolver{Loss: noRobustLoss, Epsilon: 1e-10, MaxIter: 100, LineSearch: armijoLineSearch}.FitWeighted(X, Y, W)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(result.Intercept-1) > 1e-6 || math.Abs(result.Coef[0]-2) > 1e-6 {
+		t.Errorf("weighted: line %.8g + %.8gx, want 1 + 2x", result.Intercept, result.Coef[0])
+	}
+}
+
+func TestRobustWeightsRowNumbers(t *testing.T) {
+	// the third row has the lowest weight and is the third row of the csv
+	X := [][]float64{{1}, {2}, {3}, {4}}
+	Y := []float64{2, 4, 30, 8}
+	weights := []float64{1, .9, .1, 1}
+	yPred := []float64{2, 4, 6, 8}
+	var out strings.Builder
+	writeRobustWeights(&out, weights, Y, yPred, 1)
+	if !strings.Contains(out.String(), "\trow 3: weight 0.1000 residual 24.00000000\n") {
+		t.Errorf("lowest weight is not reported as row 3:\n%s", out.String())
+	}
+
+	fname := filepath.Join(t.TempDir(), "weights.csv")
+	if err := saveRobustWeights(weights, Y, X, []float64{2}, 0, fname); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, record := range records[1:] {
+		if want := strconv.Itoa(i + 1); record[0] != want {
+			t.Errorf("row %d is numbered %s, want %s", i, record[0], want)
+		}
+	}
+}
+
+func TestRobustFlagConflicts(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-alpha", "1"}, "-robust huber is fitted with the newton solver and cannot be combined with -alpha 1"},
+		{[]string{"-solver", "qr"}, "cannot be combined with -solver qr"},
+		{[]string{"-solver", "qr", "-alpha", "0.5"}, "cannot be combined with -solver qr or -alpha 0.5"},
+	}
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("fit", flag.ContinueOnError)
+		s := newSolverFlags(fs)
+		if err := fs.Parse(append([]string{"-robust", "huber"}, tt.args...)); err != nil {
+			t.Fatal(err)
+		}
+		_, err := s.solver()
+		if err == nil || !strings.HasSuffix(err.Error(), tt.want) {
+			t.Errorf("%q: error %v, want it to end %q", tt.args, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// Solver fits the intercept and coefficients of a linear model of Y on the
+// columns of X
+type Solver interface {
+	Fit(X [][]float64, Y []float64) (*Result, error)
+}
+
+// weightedSolver is a Solver that can also fit weighted least squares, where
+// the squared error of each row counts in proportion to its weight in W
+type weightedSolver interface {
+	Solver
+	FitWeighted(X [][]float64, Y, W []float64) (*Result, error)
+}
+
+// Result holds the fitted parameters of a solver along with the trace of
+// iterations it took to converge. Closed form solvers leave the trace empty
+type Result struct {
+	Coef      []float64
+	Intercept float64
+	// Condition is the condition number of the design matrix when the solver
+	// computes it and zero otherwise
+	Condition float64
+	Trace     []Iteration
+	// Weights and Scale are the final weight of each row and the residual
+	// scale of solvers that reweight rows to resist outliers, and are nil and
+	// zero otherwise
+	Weights []float64
+	Scale   float64
+	// Inliers marks the rows a consensus solver fitted, the rest being
+	// outliers. It is nil for every other solver
+	Inliers []bool
+	// Capped is set when a stochastic solver ran out of epochs before its
+	// steps settled and returned its last estimate
+	Capped bool
+}
+
+// Iteration records the state of a solver at the end of a single iteration
+type Iteration struct {
+	Iteration int `json:"iteration"`
+	// Step is the magnitude of the change in the parameters proposed by the
+	// solver
+	Step float64 `json:"step"`
+	// StepLength is the fraction of the proposed step that was taken, the
+	// accepted line search step for newtons method and the learning rate for
+	// gradient descent
+	StepLength float64 `json:"step_length"`
+	// Loss is the objective the solver minimises evaluated at Theta
+	Loss float64 `json:"loss"`
+	// Theta is the intercept followed by the coefficients
+	Theta []float64 `json:"theta"`
+}
+
+// newIteration records an iteration that took a step of the given magnitude
+// and length to arrive at theta with the given loss
+func newIteration(i int, step, length, loss float64, theta []float64) Iteration {
+	return Iteration{
+		Iteration:  i,
+		Step:       step,
+		StepLength: length,
+		Loss:       loss,
+		Theta:      append([]float64{}, theta...),
+	}
+}
+
+// writeTrace writes one line per iteration of the trace to w
+func writeTrace(w io.Writer, trace []Iteration) {
+	for _, it := range trace {
+		fmt.Fprintf(w, "Iteration: %d\t|%c|: %.8f\t%c: %.6f\tloss: %.8f\tb: %.16f\tm: %.16f\n",
+			it.Iteration, 0x0394, it.Step, 0x03b1, it.StepLength, it.Loss, it.Theta[0], it.Theta[1:])
+	}
+}
+
+// newtonSolver fits the model with newtons method, stopping once the step
+// magnitude drops below Epsilon or failing after MaxIter iterations. Each step
+// is scaled by the length LineSearch accepts
+type newtonSolver struct {
+	Epsilon    float64
+	MaxIter    int
+	LineSearch lineSearch
+}
+
+// Fit implements Solver
+func (s newtonSolver) Fit(X [][]float64, Y []float64) (*Result, error) {
+	return s.FitWeighted(X, Y, nil)
+}
+
+// FitWeighted implements weightedSolver
+func (s newtonSolver) FitWeighted(X [][]float64, Y, W []float64) (*Result, error) {
+	coef, b, trace, err := newtonsRegression(X, Y, W, s.Epsilon, s.MaxIter, s.LineSearch)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Coef: coef, Intercept: b, Trace: trace}, nil
+}
+
+// qrSolver fits the model in closed form with a householder QR factorization
+type qrSolver struct{}
+
+// Fit implements Solver
+func (s qrSolver) Fit(X [][]float64, Y []float64) (*Result, error) {
+	return s.FitWeighted(X, Y, nil)
+}
+
+// FitWeighted implements weightedSolver
+func (qrSolver) FitWeighted(X [][]float64, Y, W []float64) (*Result, error) {
+	coef, b, cond, err := qrRegression(X, Y, W)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Coef: coef, Intercept: b, Condition: cond}, nil
+}
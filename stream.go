@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+)
+
+// streamStats accumulates the means and the sums of centered cross products of
+// the columns of a csv one row at a time using welford's update, which stays
+// accurate where the raw sums of squares would cancel catastrophically
+type streamStats struct {
+	n    int
+	mean []float64
+	// comoment[i][j] is the sum over the rows of (v_i - mean_i)(v_j - mean_j)
+	comoment [][]float64
+	delta    []float64
+}
+
+// newStreamStats returns empty statistics for rows of dims values
+func newStreamStats(dims int) *streamStats {
+	s := &streamStats{
+		mean:     make([]float64, dims),
+		comoment: make([][]float64, dims),
+		delta:    make([]float64, dims),
+	}
+	for i := range s.comoment {
+		s.comoment[i] = make([]float64, dims)
+	}
+	return s
+}
+
+// add updates the statistics with the row v
+func (s *streamStats) add(v []float64) {
+	s.n++
+	for i := range v {
+		s.delta[i] = v[i] - s.mean[i]
+		s.mean[i] += s.delta[i] / float64(s.n)
+	}
+	for i := range v {
+		for j := range v {
+			s.comoment[i][j] += s.delta[i] * (v[j] - s.mean[j])
+		}
+	}
+}
+
+// fit solves the least squares problem for the last column on the others from
+// the accumulated statistics, returning the coefficients, the intercept and
+// the residual sum of squares
+func (s *streamStats) fit() ([]float64, float64, float64, error) {
+	p := len(s.mean) - 1
+	if s.n <= p+1 {
+		return nil, 0, 0, fmt.Errorf("regression needs more rows than the %d parameters but only %d were read", p+1, s.n)
+	}
+	sxx, sxy := s.predictorComoment(), make([]float64, p)
+	for i := 0; i < p; i++ {
+		sxy[i] = s.comoment[i][p]
+	}
+	coef, err := solveLinear(sxx, sxy)
+	if err != nil {
+		return nil, 0, 0, &SolverError{Err: ErrRankDeficient, Iteration: -1, Detail: "a predictor is constant or a linear combination of the others"}
+	}
+	b := s.mean[p] - dot(coef, s.mean[:p])
+	rss := math.Max(s.comoment[p][p]-dot(coef, sxy), 0)
+	return coef, b, rss, nil
+}
+
+// covariance returns the covariance of the intercept followed by the
+// coefficients given the residual variance sigma2, using the centered form of
+// sigma^2 (X^T X)^-1
+func (s *streamStats) covariance(sigma2 float64) ([][]float64, error) {
+	p := len(s.mean) - 1
+	inv, err := invert(s.predictorComoment())
+	if err != nil {
+		return nil, err
+	}
+	xbar := s.mean[:p]
+	cov := make([][]float64, p+1)
+	for i := range cov {
+		cov[i] = make([]float64, p+1)
+	}
+	cov[0][0] = 1 / float64(s.n)
+	for i := 0; i < p; i++ {
+		for j := 0; j < p; j++ {
+			cov[i+1][j+1] = inv[i][j]
+			cov[0][0] += xbar[i] * inv[i][j] * xbar[j]
+			cov[0][i+1] -= inv[i][j] * xbar[j]
+		}
+		cov[i+1][0] = cov[0][i+1]
+	}
+	for i := range cov {
+		for j := range cov[i] {
+			cov[i][j] *= sigma2
+		}
+	}
+	return cov, nil
+}
+
+// predictorComoment returns a copy of the comoments of the predictors
+func (s *streamStats) predictorComoment() [][]float64 {
+	p := len(s.mean) - 1
+	sxx := make([][]float64, p)
+	for i := range sxx {
+		sxx[i] = append([]float64{}, s.comoment[i][:p]...)
+	}
+	return sxx
+}
+
+// reservoir keeps a uniform random sample of at most size rows from a stream of
+// unknown length
+type reservoir struct {
+	size int
+	seen int
+	rows [][]float64
+	rng  *rand.Rand
+}
+
+// add offers the row v to the sample, which keeps it with probability
+// size/seen
+func (r *reservoir) add(v []float64) {
+	r.seen++
+	if len(r.rows) < r.size {
+		r.rows = append(r.rows, append([]float64{}, v...))
+		return
+	}
+	if j := r.rng.Intn(r.seen); j < r.size {
+		r.rows[j] = append(r.rows[j][:0], v...)
+	}
+}
+
+// streamResult is the outcome of fitting a csv in a single pass
+type streamResult struct {
+	Coef      []float64
+	Intercept float64
+	Summary   FitSummary
+	Head      []string
+	Quality   DataQuality
+	// SampleX and SampleY hold a uniform random sample of the rows
+	SampleX [][]float64
+	SampleY []float64
+}
+
+// streamRegression fits the least squares regression of a csv read once in
+// constant memory, keeping only the sufficient statistics and a reservoir
+// sample of sampleSize rows seeded by seed. Only the drop, fail and
+// impute-constant missing value policies can be applied in a single pass
+func streamRegression(xstr []string, ystr string, spec missingSpec, reader *csv.Reader, sampleSize int, seed int64, level float64) (*streamResult, error) {
+	r, err := newRowReader(xstr, ystr, "", spec, reader)
+	if err != nil {
+		return nil, err
+	}
+	for i, policy := range r.policies {
+		if policy == imputeMean || policy == imputeMedian {
+			return nil, fmt.Errorf("column %s uses the %s missing value policy which needs two passes, streaming supports drop, fail and impute-constant", r.head[i], policy)
+		}
+	}
+	stats := newStreamStats(len(r.cols))
+	sample := &reservoir{size: sampleSize, rng: rand.New(rand.NewSource(seed))}
+	for {
+		values, gap, _, err := r.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		for i := range gap {
+			if gap[i] {
+				values[i] = spec.Constant
+				r.quality.Columns[i].Imputed++
+			}
+		}
+		if !isFinite(values) {
+			return nil, &SolverError{Err: ErrNonFinite, Iteration: -1, Detail: fmt.Sprintf("line %d has a non-finite value", r.line)}
+		}
+		stats.add(values)
+		sample.add(values)
+	}
+	coef, b, rss, err := stats.fit()
+	if err != nil {
+		return nil, err
+	}
+	p := len(coef)
+	result := &streamResult{
+		Coef:      coef,
+		Intercept: b,
+		Summary:   summaryFromSums(stats.n, p+1, rss, stats.comoment[p][p], r.head[p], level),
+		Head:      r.head,
+		Quality:   r.quality,
+	}
+	sigma2 := result.Summary.ResidualSE * result.Summary.ResidualSE
+	if cov, err := stats.covariance(sigma2); err == nil {
+		result.Summary.setCoefficients(cov, result.Summary.DFResid, coef, b, r.head[:p])
+	}
+	for _, row := range sample.rows {
+		result.SampleX = append(result.SampleX, row[:p])
+		result.SampleY = append(result.SampleY, row[p])
+	}
+	return result, nil
+}
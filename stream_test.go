@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/csv"
+	"math"
+	"os"
+	"testing"
+)
+
+func TestStreamStats(t *testing.T) {
+	// a large offset cancels in the raw sums of squares but not in welford's
+	// update, so the centered moments of 1e9 + (1, 2, 3, 4) are those of 1..4
+	s := newStreamStats(2)
+	for _, v := range [][]float64{{1e9 + 1, 2}, {1e9 + 2, 4}, {1e9 + 3, 5}, {1e9 + 4, 9}} {
+		s.add(v)
+	}
+	if s.n != 4 || s.mean[0] != 1e9+2.5 || s.mean[1] != 5 {
+		t.Errorf("n %d and means %v, want 4 and [%g 5]", s.n, s.mean, 1e9+2.5)
+	}
+	want := [][]float64{{5, 11}, {11, 26}}
+	for i := range want {
+		for j := range want[i] {
+			if math.Abs(s.comoment[i][j]-want[i][j]) > 1e-6 {
+				t.Errorf("comoment[%d][%d] = %.12g, want %g", i, j, s.comoment[i][j], want[i][j])
+			}
+		}
+	}
+	// the least squares line of y on x has slope 11/5 and intercept
+	// 5 - 2.2 (1e9 + 2.5), leaving 26 - 11 * 2.2 = 1.8 unexplained
+	coef, b, rss, err := s.fit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(coef[0]-2.2) > 1e-9 || math.Abs(b-(5-2.2*(1e9+2.5))) > 1e-3 || math.Abs(rss-1.8) > 1e-6 {
+		t.Errorf("fit() = %v, %.12g, %.12g, want [2.2], %.12g, 1.8", coef, b, rss, 5-2.2*(1e9+2.5))
+	}
+}
+
+func TestStreamRegressionMatchesQR(t *testing.T) {
+	tests := []struct {
+		fname string
+		xstr  []string
+		ystr  string
+	}{
+		{"regression_test.csv", []string{"0"}, "1"},
+		{"advertising.csv", []string{"TV", "Radio", "Newspaper"}, "Sales"},
+	}
+	for _, tt := range tests {
+		X, Y := readTestCSV(t, tt.fname, tt.xstr, tt.ystr)
+		coef, b, _, err := qrRegression(X, Y, nil)
+		if err != nil {
+			t.Fatalf("%s: qr: %v", tt.fname, err)
+		}
+		f, err := os.Open(tt.fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		spec, err := parseMissing("drop", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stream, err := streamRegression(tt.xstr, tt.ystr, spec, csv.NewReader(f), 10, 1, 0.95)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: stream: %v", tt.fname, err)
+		}
+		if math.Abs(stream.Intercept-b) > 1e-9 {
+			t.Errorf("%s: stream intercept %.12g, qr intercept %.12g", tt.fname, stream.Intercept, b)
+		}
+		for j := range coef {
+			if math.Abs(stream.Coef[j]-coef[j]) > 1e-9 {
+				t.Errorf("%s: stream coefficient %d %.12g, qr %.12g", tt.fname, j, stream.Coef[j], coef[j])
+			}
+		}
+		// the inference from the sufficient statistics matches that of the
+		// rows themselves
+		summary := newFitSummary(X, Y, nil, coef, b, append(tt.xstr, tt.ystr), 0.95)
+		if stream.Summary.N != summary.N || math.Abs(stream.Summary.RSquared-summary.RSquared) > 1e-12 {
+			t.Errorf("%s: stream n %d and R-squared %.12g, want %d and %.12g", tt.fname, stream.Summary.N, stream.Summary.RSquared, summary.N, summary.RSquared)
+		}
+		for i := range summary.Covariance {
+			for j := range summary.Covariance[i] {
+				want := summary.Covariance[i][j]
+				if got := stream.Summary.Covariance[i][j]; math.Abs(got-want) > 1e-9*math.Max(1, math.Abs(want)) {
+					t.Errorf("%s: stream covariance[%d][%d] %.12g, want %.12g", tt.fname, i, j, got, want)
+				}
+			}
+		}
+		want := 10
+		if len(Y) < want {
+			want = len(Y)
+		}
+		if len(stream.SampleY) != want {
+			t.Errorf("%s: reservoir kept %d rows, want %d", tt.fname, len(stream.SampleY), want)
+		}
+	}
+}
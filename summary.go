@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// FitSummary holds the goodness of fit measures of a fitted linear model along
+// with the inference for each of its parameters
+type FitSummary struct {
+	// Family is binomial for a logistic model, whose RSquared is a pseudo
+	// R-squared and whose coefficients have wald z-statistics, and empty for
+	// least squares
+	Family   string `json:"family,omitempty"`
+	Response string `json:"response"`
+	// N is the number of observations and K the number of parameters
+	// including the intercept
+	N             int     `json:"n"`
+	K             int     `json:"k"`
+	DFModel       int     `json:"df_model"`
+	DFResid       int     `json:"df_resid"`
+	RSquared      float64 `json:"r_squared"`
+	AdjRSquared   float64 `json:"adj_r_squared"`
+	ResidualSE    float64 `json:"residual_std_err"`
+	FStatistic    float64 `json:"f_statistic"`
+	FPValue       float64 `json:"f_p_value"`
+	LogLikelihood float64 `json:"log_likelihood"`
+	AIC           float64 `json:"aic"`
+	BIC           float64 `json:"bic"`
+	// MAE is NaN when the residuals are not available, as when streaming, and
+	// is written to json as null
+	MAE float64 `json:"mae"`
+	// Level is the confidence level of the coefficient intervals
+	Level        float64            `json:"level"`
+	Coefficients []CoefficientStats `json:"coefficients"`
+	// Covariance is the estimated covariance matrix of the intercept followed
+	// by the coefficients
+	Covariance [][]float64 `json:"covariance"`
+}
+
+// newFitSummary computes the summary of the model with coefficients coef and
+// intercept b on the data, where names holds the name of each predictor
+// followed by the response. With weights W the sums of squares, MAE and
+// R-squared are weighted and the log-likelihood is that of a gaussian whose
+// variance for each row is inversely proportional to its weight, as in
+// weighted least squares. Rows with zero weight are not counted as
+// observations. Coefficient inference is left empty when the covariance of
+// the parameters cannot be estimated
+func newFitSummary(X [][]float64, Y, W []float64, coef []float64, b float64, names []string, level float64) FitSummary {
+	n := weightedRows(W, len(X))
+	sumW := sumWeights(W, len(X))
+	var meanY, tss, rss, mae float64
+	for i, y := range Y {
+		meanY += weightAt(W, i) * y / sumW
+	}
+	for i := range X {
+		w := weightAt(W, i)
+		residual := Y[i] - predict(coef, X[i], b)
+		rss += w * residual * residual
+		mae += w * math.Abs(residual) / sumW
+		tss += w * (Y[i] - meanY) * (Y[i] - meanY)
+	}
+	s := summaryFromSums(n, len(coef)+1, rss, tss, names[len(names)-1], level)
+	s.MAE = mae
+	if W != nil {
+		// rows with zero weight have no information and add nothing
+		var logW float64
+		for _, w := range W {
+			if w > 0 {
+				logW += math.Log(w)
+			}
+		}
+		s.LogLikelihood += logW / 2
+		s.AIC = -2*s.LogLikelihood + 2*float64(s.K)
+		s.BIC = -2*s.LogLikelihood + float64(s.K)*math.Log(float64(n))
+	}
+	if cov, df, err := coefficientCovariance(X, Y, W, coef, b); err == nil {
+		s.setCoefficients(cov, df, coef, b, names[:len(coef)])
+	}
+	return s
+}
+
+// summaryFromSums fills in the measures of a summary that only depend on the
+// number of observations n, the number of parameters k, and the residual and
+// total sums of squares. MAE is left as NaN and the coefficients empty
+func summaryFromSums(n, k int, rss, tss float64, response string, level float64) FitSummary {
+	s := FitSummary{
+		Response: response,
+		N:        n,
+		K:        k,
+		DFModel:  k - 1,
+		DFResid:  n - k,
+		Level:    level,
+		MAE:      math.NaN(),
+	}
+	fn, fk := float64(n), float64(k)
+	s.RSquared = 1 - rss/tss
+	s.AdjRSquared = 1 - (1-s.RSquared)*(fn-1)/float64(s.DFResid)
+	s.ResidualSE = math.Sqrt(rss / float64(s.DFResid))
+	s.FStatistic = ((tss - rss) / float64(s.DFModel)) / (rss / float64(s.DFResid))
+	s.FPValue = fSurvival(s.FStatistic, float64(s.DFModel), float64(s.DFResid))
+	// gaussian log likelihood at the maximum likelihood estimate of the
+	// residual variance
+	s.LogLikelihood = -fn / 2 * (math.Log(2*math.Pi) + math.Log(rss/fn) + 1)
+	s.AIC = -2*s.LogLikelihood + 2*fk
+	s.BIC = -2*s.LogLikelihood + fk*math.Log(fn)
+	return s
+}
+
+// setCoefficients fills in the coefficient inference from the covariance of
+// the parameters and its degrees of freedom
+func (s *FitSummary) setCoefficients(cov [][]float64, df int, coef []float64, b float64, names []string) {
+	s.Coefficients = coefficientInference(cov, df, coef, b, names, s.Level)
+	s.Covariance = cov
+}
+
+// String formats the summary as a table in the style of statsmodels followed
+// by the coefficient table
+func (s FitSummary) String() string {
+	var sb strings.Builder
+	rule := strings.Repeat("=", 78) + "\n"
+	row := func(lk, lv, rk, rv string) {
+		fmt.Fprintf(&sb, "%-20s%19s   %-20s%16s\n", lk, lv, rk, rv)
+	}
+	sb.WriteString(rule)
+	if s.Family == "binomial" {
+		row("Dep. Variable:", s.Response, "Pseudo R-squared:", fmt.Sprintf("%.3f", s.RSquared))
+		row("No. Observations:", fmt.Sprint(s.N), "Adj. Pseudo R-sq.:", fmt.Sprintf("%.3f", s.AdjRSquared))
+		row("Df Residuals:", fmt.Sprint(s.DFResid), "Log-Likelihood:", fmt.Sprintf("%.5g", s.LogLikelihood))
+		row("Df Model:", fmt.Sprint(s.DFModel), "AIC:", fmt.Sprintf("%.4g", s.AIC))
+		row("Family:", s.Family, "BIC:", fmt.Sprintf("%.4g", s.BIC))
+		sb.WriteString(rule)
+		if s.Coefficients != nil {
+			sb.WriteString(testTable(s.Coefficients, s.Level, "z"))
+			sb.WriteString(rule)
+		}
+		return sb.String()
+	}
+	row("Dep. Variable:", s.Response, "R-squared:", fmt.Sprintf("%.3f", s.RSquared))
+	row("No. Observations:", fmt.Sprint(s.N), "Adj. R-squared:", fmt.Sprintf("%.3f", s.AdjRSquared))
+	row("Df Residuals:", fmt.Sprint(s.DFResid), "F-statistic:", fmt.Sprintf("%.4g", s.FStatistic))
+	row("Df Model:", fmt.Sprint(s.DFModel), "Prob (F-statistic):", fmt.Sprintf("%.3g", s.FPValue))
+	row("Residual Std. Err:", fmt.Sprintf("%.4f", s.ResidualSE), "Log-Likelihood:", fmt.Sprintf("%.5g", s.LogLikelihood))
+	mae := fmt.Sprintf("%.4f", s.MAE)
+	if math.IsNaN(s.MAE) {
+		mae = "-"
+	}
+	row("MAE:", mae, "AIC:", fmt.Sprintf("%.4g", s.AIC))
+	row("", "", "BIC:", fmt.Sprintf("%.4g", s.BIC))
+	sb.WriteString(rule)
+	if s.Coefficients != nil {
+		sb.WriteString(coefficientTable(s.Coefficients, s.Level))
+		sb.WriteString(rule)
+	}
+	return sb.String()
+}
+
+// nullable returns v, or nil when it is NaN or infinite, which json cannot
+// represent
+func nullable(v float64) *float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	return &v
+}
+
+// orNaN returns the value p points to, or NaN when a null left it nil
+func orNaN(p *float64) float64 {
+	if p == nil {
+		return math.NaN()
+	}
+	return *p
+}
+
+// nullableSummary holds the measures of a summary that may not be finite, such
+// as the log-likelihood of a perfect fit or the residual standard error
+// without residual degrees of freedom, as written to json
+type nullableSummary struct {
+	RSquared      *float64     `json:"r_squared"`
+	AdjRSquared   *float64     `json:"adj_r_squared"`
+	ResidualSE    *float64     `json:"residual_std_err"`
+	FStatistic    *float64     `json:"f_statistic"`
+	FPValue       *float64     `json:"f_p_value"`
+	LogLikelihood *float64     `json:"log_likelihood"`
+	AIC           *float64     `json:"aic"`
+	BIC           *float64     `json:"bic"`
+	MAE           *float64     `json:"mae"`
+	Covariance    [][]*float64 `json:"covariance"`
+}
+
+// MarshalJSON implements json.Marshaler, writing every measure and covariance
+// that is NaN or infinite as null
+func (s FitSummary) MarshalJSON() ([]byte, error) {
+	type plain FitSummary
+	n := nullableSummary{
+		RSquared:      nullable(s.RSquared),
+		AdjRSquared:   nullable(s.AdjRSquared),
+		ResidualSE:    nullable(s.ResidualSE),
+		FStatistic:    nullable(s.FStatistic),
+		FPValue:       nullable(s.FPValue),
+		LogLikelihood: nullable(s.LogLikelihood),
+		AIC:           nullable(s.AIC),
+		BIC:           nullable(s.BIC),
+		MAE:           nullable(s.MAE),
+	}
+	if s.Covariance != nil {
+		n.Covariance = make([][]*float64, len(s.Covariance))
+		for i, row := range s.Covariance {
+			n.Covariance[i] = make([]*float64, len(row))
+			for j, v := range row {
+				n.Covariance[i][j] = nullable(v)
+			}
+		}
+	}
+	// the plain fields sit a level deeper so that the nullable ones of the
+	// same name take their place
+	type deeper struct{ plain }
+	return json.Marshal(struct {
+		deeper
+		nullableSummary
+	}{deeper{plain(s)}, n})
+}
+
+// UnmarshalJSON implements json.Unmarshaler, reading each null written by
+// MarshalJSON as NaN
+func (s *FitSummary) UnmarshalJSON(data []byte) error {
+	type plain FitSummary
+	type deeper struct{ *plain }
+	var n nullableSummary
+	aux := struct {
+		deeper
+		*nullableSummary
+	}{deeper{(*plain)(s)}, &n}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	s.RSquared, s.AdjRSquared = orNaN(n.RSquared), orNaN(n.AdjRSquared)
+	s.ResidualSE = orNaN(n.ResidualSE)
+	s.FStatistic, s.FPValue = orNaN(n.FStatistic), orNaN(n.FPValue)
+	s.LogLikelihood, s.AIC, s.BIC = orNaN(n.LogLikelihood), orNaN(n.AIC), orNaN(n.BIC)
+	s.MAE = orNaN(n.MAE)
+	s.Covariance = nil
+	if n.Covariance != nil {
+		s.Covariance = make([][]float64, len(n.Covariance))
+		for i, row := range n.Covariance {
+			s.Covariance[i] = make([]float64, len(row))
+			for j, v := range row {
+				s.Covariance[i][j] = orNaN(v)
+			}
+		}
+	}
+	return nil
+}
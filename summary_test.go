@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFitSummaryJSONNull(t *testing.T) {
+	// the measures of a perfect fit, which has no residual variance, are
+	// infinite or undefined where they divide by it
+	s := FitSummary{
+		Response:      "y",
+		N:             4,
+		K:             2,
+		DFModel:       1,
+		DFResid:       2,
+		RSquared:      math.NaN(),
+		AdjRSquared:   math.NaN(),
+		ResidualSE:    0,
+		FStatistic:    math.Inf(1),
+		FPValue:       0,
+		LogLikelihood: math.Inf(1),
+		AIC:           math.Inf(-1),
+		BIC:           math.Inf(-1),
+		MAE:           0,
+		Level:         .95,
+		Coefficients: []CoefficientStats{
+			{Name: "Intercept", Estimate: 2, StdErr: 0, TStat: math.NaN(), PValue: math.NaN(), Lower: 2, Upper: 2},
+			{Name: "x", Estimate: 0.5, StdErr: 0, TStat: math.Inf(1), PValue: 0, Lower: 0.5, Upper: 0.5},
+		},
+		Covariance: [][]float64{{0, math.NaN()}, {math.NaN(), 0}},
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"r_squared":null`, `"adj_r_squared":null`, `"f_statistic":null`, `"log_likelihood":null`,
+		`"aic":null`, `"bic":null`, `"residual_std_err":0`, `"t":null`, `"p_value":null`, `"covariance":[[0,null],[null,0]]`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("json %s does not contain %s", data, want)
+		}
+	}
+	// every field appears once, the nullable ones in place of the plain ones
+	if n := strings.Count(string(data), `"r_squared"`); n != 1 {
+		t.Errorf("r_squared is written %d times", n)
+	}
+
+	var got FitSummary
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	// NaN never equals itself and infinities come back as NaN, so compare
+	// their encodings instead
+	again, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(data) {
+		t.Errorf("round trip changed the json\n%s\nto\n%s", data, again)
+	}
+	for _, v := range []float64{got.RSquared, got.FStatistic, got.LogLikelihood, got.AIC, got.Coefficients[0].TStat, got.Coefficients[1].TStat, got.Covariance[0][1]} {
+		if !math.IsNaN(v) {
+			t.Errorf("a null was read back as %g, want NaN", v)
+		}
+	}
+	if got.N != s.N || got.DFResid != s.DFResid || got.Response != s.Response || got.ResidualSE != 0 || got.Level != s.Level {
+		t.Errorf("read back %+v, wrote %+v", got, s)
+	}
+	if !reflect.DeepEqual([]string{got.Coefficients[0].Name, got.Coefficients[1].Name}, []string{"Intercept", "x"}) || got.Coefficients[1].Estimate != 0.5 {
+		t.Errorf("read back coefficients %+v", got.Coefficients)
+	}
+}
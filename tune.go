@@ -0,0 +1,392 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// paramSpec is the values a tuned flag may take, either a list of values or
+// a distribution with bounds that random search samples from
+type paramSpec struct {
+	Values []string
+	// Distribution is uniform, log-uniform or int-uniform, all inclusive of
+	// Low and High
+	Distribution string
+	Low          float64
+	High         float64
+}
+
+// parseParam parses a -param flag of the form name=v1,v2,... or
+// name=distribution:low:high
+func parseParam(s string) (string, paramSpec, error) {
+	eq := strings.Index(s, "=")
+	if eq <= 0 || eq == len(s)-1 {
+		return "", paramSpec{}, fmt.Errorf("parameter %q must be name=v1,v2,... or name=distribution:low:high", s)
+	}
+	name, value := strings.TrimPrefix(s[:eq], "-"), s[eq+1:]
+	if parts := strings.Split(value, ":"); len(parts) == 3 {
+		low, err1 := strconv.ParseFloat(parts[1], 64)
+		high, err2 := strconv.ParseFloat(parts[2], 64)
+		if err1 != nil || err2 != nil {
+			return "", paramSpec{}, fmt.Errorf("parameter %s: the bounds of %q are not numbers", name, value)
+		}
+		p := paramSpec{Distribution: parts[0], Low: low, High: high}
+		return name, p, p.check(name)
+	}
+	p := paramSpec{Values: strings.Split(value, ",")}
+	return name, p, p.check(name)
+}
+
+// check returns an error unless the spec has values or a valid distribution
+func (p paramSpec) check(name string) error {
+	if p.Distribution == "" {
+		if len(p.Values) == 0 {
+			return fmt.Errorf("parameter %s has no values", name)
+		}
+		return nil
+	}
+	switch p.Distribution {
+	case "uniform", "int-uniform":
+	case "log-uniform":
+		if p.Low <= 0 {
+			return fmt.Errorf("parameter %s: log-uniform bounds must be positive, got %g", name, p.Low)
+		}
+	default:
+		return fmt.Errorf("parameter %s: unknown distribution %q, must be uniform, log-uniform or int-uniform", name, p.Distribution)
+	}
+	if p.Low > p.High {
+		return fmt.Errorf("parameter %s: low %g is above high %g", name, p.Low, p.High)
+	}
+	if p.Distribution == "int-uniform" && math.Ceil(p.Low) > math.Floor(p.High) {
+		return fmt.Errorf("parameter %s: no integer lies between low %g and high %g", name, p.Low, p.High)
+	}
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, reading an array of values or an
+// object with distribution, low and high fields
+func (p *paramSpec) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		var values []interface{}
+		if err := dec.Decode(&values); err != nil {
+			return err
+		}
+		p.Values = make([]string, len(values))
+		for i, v := range values {
+			p.Values[i] = fmt.Sprint(v)
+		}
+		return nil
+	}
+	var dist struct {
+		Distribution string  `json:"distribution"`
+		Low          float64 `json:"low"`
+		High         float64 `json:"high"`
+	}
+	if err := json.Unmarshal(data, &dist); err != nil {
+		return err
+	}
+	p.Distribution, p.Low, p.High = dist.Distribution, dist.Low, dist.High
+	return nil
+}
+
+// sample draws a value of the parameter from rng
+func (p paramSpec) sample(rng *rand.Rand) string {
+	switch p.Distribution {
+	case "uniform":
+		return formatFloat(p.Low + rng.Float64()*(p.High-p.Low))
+	case "log-uniform":
+		lo, hi := math.Log(p.Low), math.Log(p.High)
+		return formatFloat(math.Exp(lo + rng.Float64()*(hi-lo)))
+	case "int-uniform":
+		lo, hi := int(math.Ceil(p.Low)), int(math.Floor(p.High))
+		return strconv.Itoa(lo + rng.Intn(hi-lo+1))
+	}
+	return p.Values[rng.Intn(len(p.Values))]
+}
+
+// searchSpec is a search space read from a json file such as
+//
+//	{
+//	  "search": "random",
+//	  "trials": 30,
+//	  "params": {
+//	    "alpha": {"distribution": "log-uniform", "low": 1e-4, "high": 10},
+//	    "l1-ratio": [0, 0.5, 1]
+//	  }
+//	}
+//
+// where each parameter is the name of a solver or feature flag
+type searchSpec struct {
+	Search string               `json:"search"`
+	Trials int                  `json:"trials"`
+	Params map[string]paramSpec `json:"params"`
+}
+
+// loadSearchSpec reads a search spec from a json file
+func loadSearchSpec(fname string) (*searchSpec, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var spec searchSpec
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("%s: %v", fname, err)
+	}
+	for name, p := range spec.Params {
+		if err := p.check(name); err != nil {
+			return nil, fmt.Errorf("%s: %v", fname, err)
+		}
+	}
+	return &spec, nil
+}
+
+// candidates returns the settings to evaluate, each a value for every name in
+// names which are the parameters in sorted order. Grid search takes every
+// combination of the listed values while random search draws trials settings
+// from rng
+func (s *searchSpec) candidates(rng *rand.Rand) ([]string, [][]string, error) {
+	names := make([]string, 0, len(s.Params))
+	for name := range s.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return nil, nil, fmt.Errorf("the search space has no parameters, add them with -param or -spec")
+	}
+	var cands [][]string
+	switch s.Search {
+	case "grid":
+		cands = [][]string{{}}
+		for _, name := range names {
+			p := s.Params[name]
+			if p.Distribution != "" {
+				return nil, nil, fmt.Errorf("grid search needs a list of values for %s, only random search samples from a distribution", name)
+			}
+			var next [][]string
+			for _, c := range cands {
+				for _, v := range p.Values {
+					next = append(next, append(append([]string{}, c...), v))
+				}
+			}
+			cands = next
+		}
+	case "random":
+		if s.Trials < 1 {
+			return nil, nil, fmt.Errorf("random search needs a positive number of trials, got %d", s.Trials)
+		}
+		for t := 0; t < s.Trials; t++ {
+			c := make([]string, len(names))
+			for j, name := range names {
+				c[j] = s.Params[name].sample(rng)
+			}
+			cands = append(cands, c)
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown search %q, must be grid or random", s.Search)
+	}
+	return names, cands, nil
+}
+
+// tunableFlags returns a flag set with the solver and feature flags, the only
+// flags a search may tune
+func tunableFlags() (*flag.FlagSet, *solverFlags, *featureFlags) {
+	fs := flag.NewFlagSet("candidate", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	return fs, newSolverFlags(fs), newFeatureFlags(fs)
+}
+
+// checkTunable returns an error for the first name that is not a tunable flag
+func checkTunable(names []string) error {
+	fs, _, _ := tunableFlags()
+	for _, name := range names {
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("-%s is not a solver or feature flag and cannot be tuned", name)
+		}
+	}
+	return nil
+}
+
+// candidateFlags returns the solver and feature flags of the base flag set
+// overridden by the candidate values of the named flags. Flags that are not
+// solver or feature flags cannot be tuned
+func candidateFlags(base *flag.FlagSet, names, values []string) (*solverFlags, *featureFlags, error) {
+	if err := checkTunable(names); err != nil {
+		return nil, nil, err
+	}
+	fs, solverOpts, featureOpts := tunableFlags()
+	base.Visit(func(f *flag.Flag) {
+		if fs.Lookup(f.Name) != nil {
+			fs.Set(f.Name, f.Value.String())
+		}
+	})
+	for j, name := range names {
+		if err := fs.Set(name, values[j]); err != nil {
+			return nil, nil, fmt.Errorf("-%s=%s: %v", name, values[j], err)
+		}
+	}
+	return solverOpts, featureOpts, nil
+}
+
+// tuneResult is the cross-validated performance of one candidate
+type tuneResult struct {
+	Rank   int               `json:"rank"`
+	Params map[string]string `json:"params"`
+	// Mean and SD are the fold metrics, absent when the candidate failed
+	Mean  *metrics `json:"mean,omitempty"`
+	SD    *metrics `json:"sd,omitempty"`
+	Error string   `json:"error,omitempty"`
+	// score is the metric candidates are ranked by, lower is better
+	score float64
+}
+
+// tuneOutput is the document written by tune -format json
+type tuneOutput struct {
+	Search  string       `json:"search"`
+	Metric  string       `json:"metric"`
+	Results []tuneResult `json:"results"`
+	Best    *fitOutput   `json:"best"`
+}
+
+// metricScore returns the named metric of m such that lower is better
+func metricScore(m metrics, metric string) float64 {
+	switch metric {
+	case "mae":
+		return m.MAE
+	case "r2":
+		return -m.RSquared
+	}
+	return m.RMSE
+}
+
+// tune cross-validates every candidate on the splits with a pool of workers,
+// each evaluating one candidate at a time with its folds in turn, and returns
+// the results ranked by the metric. Candidates that fail are ranked last with
+// their error
+func tune(base *flag.FlagSet, X [][]float64, Y []float64, head []string, splits []split, names []string, cands [][]string, metric string, workers int) []tuneResult {
+	if workers < 1 {
+		workers = 1
+	}
+	results := make([]tuneResult, len(cands))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = evaluateCandidate(base, X, Y, head, splits, names, cands[i], metric)
+			}
+		}()
+	}
+	for i := range cands {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	// the stable sort keeps ties in candidate order so the ranking only
+	// depends on the seed
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i].score, results[j].score
+		return a < b || !math.IsNaN(a) && math.IsNaN(b)
+	})
+	for i := range results {
+		results[i].Rank = i + 1
+	}
+	return results
+}
+
+// evaluateCandidate cross-validates the model configured by one candidate
+func evaluateCandidate(base *flag.FlagSet, X [][]float64, Y []float64, head []string, splits []split, names, values []string, metric string) tuneResult {
+	res := tuneResult{Params: make(map[string]string, len(names)), score: math.NaN()}
+	for j, name := range names {
+		res.Params[name] = values[j]
+	}
+	solverOpts, featureOpts, err := candidateFlags(base, names, values)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	solver, err := solverOpts.solver()
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	cv, err := crossValidate(X, Y, splits, solverFit(solver, featureOpts, head), 1)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.Mean, res.SD, res.score = &cv.Mean, &cv.SD, metricScore(cv.Mean, metric)
+	return res
+}
+
+// writeTuneText writes the ranked table of results with a column per
+// parameter to w
+func writeTuneText(w io.Writer, names []string, results []tuneResult) {
+	format := func(v float64) string {
+		if math.IsNaN(v) {
+			return "-"
+		}
+		return fmt.Sprintf("%.6f", v)
+	}
+	fmt.Fprintf(w, "%-6s", "Rank")
+	for _, name := range names {
+		fmt.Fprintf(w, "%14s", name)
+	}
+	fmt.Fprintf(w, "%14s%14s%14s%14s\n", "MAE", "RMSE", "R2", "SD RMSE")
+	for _, r := range results {
+		fmt.Fprintf(w, "%-6d", r.Rank)
+		for _, name := range names {
+			fmt.Fprintf(w, "%14s", r.Params[name])
+		}
+		if r.Error != "" {
+			fmt.Fprintf(w, "  failed: %s\n", r.Error)
+			continue
+		}
+		fmt.Fprintf(w, "%14s%14s%14s%14s\n", format(r.Mean.MAE), format(r.Mean.RMSE), format(r.Mean.RSquared), format(r.SD.RMSE))
+	}
+}
+
+// writeTuneCSV writes the ranked table of results to w, leaving the metrics of
+// failed candidates blank
+func writeTuneCSV(w io.Writer, names []string, results []tuneResult) error {
+	format := func(v float64) string {
+		if math.IsNaN(v) {
+			return ""
+		}
+		return formatFloat(v)
+	}
+	cw := csv.NewWriter(w)
+	cw.Write(append(append([]string{"rank"}, names...), "mae", "rmse", "r_squared", "sd_rmse", "error"))
+	for _, r := range results {
+		row := []string{strconv.Itoa(r.Rank)}
+		for _, name := range names {
+			row = append(row, r.Params[name])
+		}
+		if r.Error != "" {
+			row = append(row, "", "", "", "", r.Error)
+		} else {
+			row = append(row, format(r.Mean.MAE), format(r.Mean.RMSE), format(r.Mean.RSquared), format(r.SD.RMSE), "")
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
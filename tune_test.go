@@ -0,0 +1,227 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestParseParam(t *testing.T) {
+	tests := []struct {
+		s    string
+		name string
+		want paramSpec
+		ok   bool
+	}{
+		{"alpha=0.1,1,10", "alpha", paramSpec{Values: []string{"0.1", "1", "10"}}, true},
+		{"-solver=qr", "solver", paramSpec{Values: []string{"qr"}}, true},
+		{"alpha=log-uniform:1e-4:10", "alpha", paramSpec{Distribution: "log-uniform", Low: 1e-4, High: 10}, true},
+		{"degree=int-uniform:1:5", "degree", paramSpec{Distribution: "int-uniform", Low: 1, High: 5}, true},
+		{"alpha", "", paramSpec{}, false},
+		{"=1,2", "", paramSpec{}, false},
+		{"alpha=", "", paramSpec{}, false},
+		{"alpha=normal:0:1", "", paramSpec{}, false},
+		{"alpha=log-uniform:0:1", "", paramSpec{}, false},
+		{"alpha=uniform:2:1", "", paramSpec{}, false},
+		{"alpha=uniform:a:1", "", paramSpec{}, false},
+		{"degree=int-uniform:1.2:1.8", "", paramSpec{}, false},
+	}
+	for _, tt := range tests {
+		name, p, err := parseParam(tt.s)
+		if (err == nil) != tt.ok {
+			t.Errorf("parseParam(%q) error %v, want ok %v", tt.s, err, tt.ok)
+			continue
+		}
+		if tt.ok && (name != tt.name || !reflect.DeepEqual(p, tt.want)) {
+			t.Errorf("parseParam(%q) = %s, %+v, want %s, %+v", tt.s, name, p, tt.name, tt.want)
+		}
+	}
+}
+
+func TestParamSample(t *testing.T) {
+	tests := []struct {
+		p paramSpec
+		// integer is set when every sample must be a whole number
+		integer bool
+	}{
+		{paramSpec{Distribution: "uniform", Low: -1, High: 2}, false},
+		{paramSpec{Distribution: "log-uniform", Low: 1e-4, High: 10}, false},
+		{paramSpec{Distribution: "int-uniform", Low: 0.5, High: 3.5}, true},
+		{paramSpec{Values: []string{"1", "2", "3"}}, true},
+	}
+	for _, tt := range tests {
+		rng := rand.New(rand.NewSource(3))
+		// a log-uniform sample falls in each decade equally often, so about
+		// a fifth of them lie below 1e-3
+		var belowMilli int
+		seen := map[string]bool{}
+		const draws = 2000
+		for i := 0; i < draws; i++ {
+			s := tt.p.sample(rng)
+			seen[s] = true
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				t.Fatalf("%+v: sample %q is not a number", tt.p, s)
+			}
+			if tt.p.Distribution != "" && (v < tt.p.Low || v > tt.p.High) {
+				t.Errorf("%+v: sample %g is out of bounds", tt.p, v)
+			}
+			if tt.integer && v != math.Trunc(v) {
+				t.Errorf("%+v: sample %g is not an integer", tt.p, v)
+			}
+			if v < 1e-3 {
+				belowMilli++
+			}
+		}
+		if tt.p.Distribution == "log-uniform" && (belowMilli < draws/5-100 || belowMilli > draws/5+100) {
+			t.Errorf("%+v: %d of %d samples are below 1e-3, want about %d", tt.p, belowMilli, draws, draws/5)
+		}
+		if tt.integer && len(seen) != 3 {
+			t.Errorf("%+v: sampled %d distinct values, want 3", tt.p, len(seen))
+		}
+	}
+}
+
+func TestSearchCandidates(t *testing.T) {
+	grid := &searchSpec{Search: "grid", Params: map[string]paramSpec{
+		"l1-ratio": {Values: []string{"0", "1"}},
+		"alpha":    {Values: []string{"0.1", "1", "10"}},
+	}}
+	names, cands, err := grid.candidates(rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"alpha", "l1-ratio"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names %q, want %q", names, want)
+	}
+	// the last parameter in name order varies fastest
+	want := [][]string{
+		{"0.1", "0"}, {"0.1", "1"},
+		{"1", "0"}, {"1", "1"},
+		{"10", "0"}, {"10", "1"},
+	}
+	if !reflect.DeepEqual(cands, want) {
+		t.Errorf("grid candidates %q, want %q", cands, want)
+	}
+
+	random := &searchSpec{Search: "random", Trials: 20, Params: map[string]paramSpec{
+		"alpha":    {Distribution: "log-uniform", Low: 1e-3, High: 1},
+		"l1-ratio": {Values: []string{"0", "0.5", "1"}},
+	}}
+	_, first, err := random.candidates(rand.New(rand.NewSource(5)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, again, _ := random.candidates(rand.New(rand.NewSource(5)))
+	_, other, _ := random.candidates(rand.New(rand.NewSource(6)))
+	if len(first) != random.Trials {
+		t.Errorf("random search drew %d candidates, want %d", len(first), random.Trials)
+	}
+	if !reflect.DeepEqual(first, again) {
+		t.Error("the same seed drew different candidates")
+	}
+	if reflect.DeepEqual(first, other) {
+		t.Error("different seeds drew the same candidates")
+	}
+
+	for _, s := range []*searchSpec{
+		{Search: "grid", Params: map[string]paramSpec{"alpha": {Distribution: "uniform", Low: 0, High: 1}}},
+		{Search: "random", Trials: 0, Params: map[string]paramSpec{"alpha": {Values: []string{"1"}}}},
+		{Search: "bayes", Params: map[string]paramSpec{"alpha": {Values: []string{"1"}}}},
+		{Search: "grid"},
+	} {
+		if _, _, err := s.candidates(rand.New(rand.NewSource(1))); err == nil {
+			t.Errorf("search %+v was accepted", s)
+		}
+	}
+}
+
+func TestCandidateFlags(t *testing.T) {
+	base := flag.NewFlagSet("tune", flag.ContinueOnError)
+	base.SetOutput(ioutil.Discard)
+	newSolverFlags(base)
+	newFeatureFlags(base)
+	base.String("i", "", "")
+	if err := base.Parse([]string{"-solver", "newton", "-max-iter", "50", "-i", "data.csv"}); err != nil {
+		t.Fatal(err)
+	}
+	first, _, err := candidateFlags(base, []string{"alpha", "l1-ratio"}, []string{"0.5", "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the second candidate leaves l1-ratio at its default rather than taking
+	// the value of the first
+	second, features, err := candidateFlags(base, []string{"alpha"}, []string{"2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *first.alpha != 0.5 || *first.l1Ratio != 1 || *first.maxIter != 50 {
+		t.Errorf("first candidate has alpha %g, l1-ratio %g and max-iter %d", *first.alpha, *first.l1Ratio, *first.maxIter)
+	}
+	if *second.alpha != 2 || *second.l1Ratio != 0 || *second.maxIter != 50 || *features.degree != 1 {
+		t.Errorf("second candidate has alpha %g, l1-ratio %g, max-iter %d and degree %d", *second.alpha, *second.l1Ratio, *second.maxIter, *features.degree)
+	}
+	if *first.alpha != 0.5 {
+		t.Errorf("the second candidate changed the alpha of the first to %g", *first.alpha)
+	}
+
+	if _, _, err := candidateFlags(base, []string{"i"}, []string{"other.csv"}); err == nil {
+		t.Error("the input flag was tuned")
+	}
+	if _, _, err := candidateFlags(base, []string{"alpha"}, []string{"much"}); err == nil {
+		t.Error("a non numeric alpha was accepted")
+	}
+}
+
+func TestSummarizeFitBestCandidate(t *testing.T) {
+	// the outliers pull a plain fit away, so a summary that ignored the
+	// robust weights or the inliers of the winner would not describe it
+	X := [][]float64{{1}, {2}, {3}, {4}, {5}, {6}, {7}, {8}, {9}, {10}}
+	Y := []float64{3.1, 4.9, 7.05, 9, 10.95, 13.1, 15, 40, 19, -20}
+	head := []string{"x", "y"}
+	base := flag.NewFlagSet("tune", flag.ContinueOnError)
+	base.SetOutput(ioutil.Discard)
+	newSolverFlags(base)
+	newFeatureFlags(base)
+	if err := base.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		params []string
+		values []string
+	}{
+		{"robust", []string{"robust"}, []string{"huber"}},
+		{"ransac", []string{"solver"}, []string{"ransac"}},
+	}
+	for _, tt := range tests {
+		solverOpts, _, err := candidateFlags(base, tt.params, tt.values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := fitWith(solverOpts, nil, X, Y, nil)
+		summary, fitX, fitY, fitW := summarizeFit(solverOpts, result, X, Y, nil, head, .95)
+		var want FitSummary
+		switch tt.name {
+		case "robust":
+			want = newFitSummary(X, Y, result.Weights, result.Coef, result.Intercept, head, .95)
+			if !reflect.DeepEqual(fitW, result.Weights) {
+				t.Errorf("robust: summary weights %v, want the robust weights %v", fitW, result.Weights)
+			}
+		case "ransac":
+			subX, subY, _ := inlierRows(result.Inliers, X, Y, nil)
+			want = newFitSummary(subX, subY, nil, result.Coef, result.Intercept, head, .95)
+			if len(fitX) != len(subX) || len(fitY) != len(subY) || len(fitX) == len(X) {
+				t.Errorf("ransac: summary of %d rows, want the %d inliers", len(fitX), len(subX))
+			}
+		}
+		if summary.N != want.N || summary.RSquared != want.RSquared || summary.ResidualSE != want.ResidualSE {
+			t.Errorf("%s: summary of %d rows with r2 %g and se %g, want %d rows with r2 %g and se %g",
+				tt.name, summary.N, summary.RSquared, summary.ResidualSE, want.N, want.RSquared, want.ResidualSE)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// weightAt returns the weight of row i, one when the rows are unweighted
+func weightAt(W []float64, i int) float64 {
+	if W == nil {
+		return 1
+	}
+	return W[i]
+}
+
+// sumWeights returns the sum of the weights of n rows, which is n when the rows
+// are unweighted
+func sumWeights(W []float64, n int) float64 {
+	if W == nil {
+		return float64(n)
+	}
+	var sum float64
+	for _, w := range W {
+		sum += w
+	}
+	return sum
+}
+
+// weightedRows returns the number of the n rows with a positive weight, which
+// are the observations that inform a weighted fit, or n when the rows are
+// unweighted
+func weightedRows(W []float64, n int) int {
+	if W == nil {
+		return n
+	}
+	var count int
+	for _, w := range W {
+		if w > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// checkWeights returns an error unless every weight is finite and not
+// negative and the weights leave something to fit
+func checkWeights(W []float64) error {
+	for i, w := range W {
+		if w < 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+			return fmt.Errorf("row %d has the weight %g, weights must be finite and not negative", i, w)
+		}
+	}
+	if W != nil && sumWeights(W, len(W)) == 0 {
+		return fmt.Errorf("every row has a weight of zero")
+	}
+	return nil
+}
+
+// weightedMAE returns the mean absolute error of the predictions yPred of Y
+// with each row weighted by W, or unweighted when W is nil
+func weightedMAE(Y, yPred, W []float64) (mae float64) {
+	n := sumWeights(W, len(Y))
+	for i := range Y {
+		mae += weightAt(W, i) * math.Abs(Y[i]-yPred[i]) / n
+	}
+	return
+}
+
+// weightedCorrelation returns the correlation coefficient of X and Y with
+// each pair weighted by W, the weighted covariance over the product of the
+// weighted standard deviations
+func weightedCorrelation(X, Y, W []float64) float64 {
+	n := sumWeights(W, len(X))
+	var meanX, meanY float64
+	for i := range X {
+		meanX += weightAt(W, i) * X[i] / n
+		meanY += weightAt(W, i) * Y[i] / n
+	}
+	var sxy, sxx, syy float64
+	for i := range X {
+		w := weightAt(W, i)
+		sxy += w * (X[i] - meanX) * (Y[i] - meanY)
+		sxx += w * (X[i] - meanX) * (X[i] - meanX)
+		syy += w * (Y[i] - meanY) * (Y[i] - meanY)
+	}
+	return sxy / math.Sqrt(sxx*syy)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestWeightedRows(t *testing.T) {
+	tests := []struct {
+		W    []float64
+		n    int
+		want int
+	}{
+		{nil, 5, 5},
+		{[]float64{1, 2, 0.5}, 3, 3},
+		{[]float64{1, 0, 2, 0}, 4, 2},
+		{[]float64{0, 0}, 2, 0},
+	}
+	for _, tt := range tests {
+		if got := weightedRows(tt.W, tt.n); got != tt.want {
+			t.Errorf("weightedRows(%v, %d) = %d, want %d", tt.W, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestCheckWeights(t *testing.T) {
+	tests := []struct {
+		W  []float64
+		ok bool
+	}{
+		{nil, true},
+		{[]float64{1, 0, 2.5}, true},
+		{[]float64{1, -0.5, 2}, false},
+		{[]float64{1, math.NaN()}, false},
+		{[]float64{math.Inf(1), 1}, false},
+		{[]float64{0, 0, 0}, false},
+	}
+	for _, tt := range tests {
+		if err := checkWeights(tt.W); (err == nil) != tt.ok {
+			t.Errorf("checkWeights(%v) = %v, want ok %v", tt.W, err, tt.ok)
+		}
+	}
+}
+
+func TestIntegerWeightsDuplicateRows(t *testing.T) {
+	X := [][]float64{{1, 0.5}, {2, 1.5}, {3, 1}, {4, 3}, {5, 2.5}, {6, 2}}
+	Y := []float64{2.3, 4.1, 5.2, 8.9, 9.4, 10.8}
+	W := []float64{1, 3, 0, 2, 1, 4}
+	// repeat each row as often as its weight, dropping the zero weight row
+	var dupX [][]float64
+	var dupY []float64
+	for i := range X {
+		for k := 0; k < int(W[i]); k++ {
+			dupX, dupY = append(dupX, X[i]), append(dupY, Y[i])
+		}
+	}
+	solvers := []struct {
+		name   string
+		solver weightedSolver
+	}{
+		{"newton", newtonSolver{Epsilon: 1e-10, MaxIter: 100, LineSearch: armijoLineSearch}},
+		{"qr", qrSolver{}},
+	}
+	qr, err := qrSolver{}.Fit(dupX, dupY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append([]float64{qr.Intercept}, qr.Coef...)
+	for _, s := range solvers {
+		result, err := s.solver.FitWeighted(X, Y, W)
+		if err != nil {
+			t.Fatalf("%s: %v", s.name, err)
+		}
+		got := append([]float64{result.Intercept}, result.Coef...)
+		for j := range want {
+			if math.Abs(got[j]-want[j]) > 1e-9 {
+				t.Errorf("%s: weighted parameters %v, duplicated rows %v", s.name, got, want)
+				break
+			}
+		}
+	}
+
+	// the weighted summary counts the rows with a positive weight as the
+	// observations but has the sums of squares of the duplicated rows
+	head := []string{"x1", "x2", "y"}
+	weighted := newFitSummary(X, Y, W, qr.Coef, qr.Intercept, head, .95)
+	dup := newFitSummary(dupX, dupY, nil, qr.Coef, qr.Intercept, head, .95)
+	if weighted.N != 5 || weighted.DFResid != 2 {
+		t.Errorf("weighted summary has %d observations and %d residual degrees of freedom, want 5 and 2", weighted.N, weighted.DFResid)
+	}
+	if math.Abs(weighted.RSquared-dup.RSquared) > 1e-12 {
+		t.Errorf("weighted R-squared %g, duplicated rows %g", weighted.RSquared, dup.RSquared)
+	}
+}
+
+func TestWeightedNewtonMatchesQR(t *testing.T) {
+	X, Y := readTestCSV(t, "advertising.csv", []string{"TV", "Radio", "Newspaper"}, "Sales")
+	// weights that vary over the rows, leaving every third one out
+	W := make([]float64, len(X))
+	for i := range W {
+		W[i] = float64(i%3) * (1 + math.Sin(float64(i)))
+	}
+	newton, err := newtonSolver{Epsilon: 1e-10, MaxIter: 100, LineSearch: wolfeLineSearch}.FitWeighted(X, Y, W)
+	if err != nil {
+		t.Fatal(err)
+	}
+	qr, err := qrSolver{}.FitWeighted(X, Y, W)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(newton.Intercept-qr.Intercept) > 1e-8 {
+		t.Errorf("newton intercept %.12g, qr %.12g", newton.Intercept, qr.Intercept)
+	}
+	for j := range qr.Coef {
+		if math.Abs(newton.Coef[j]-qr.Coef[j]) > 1e-8 {
+			t.Errorf("newton coefficient %d %.12g, qr %.12g", j, newton.Coef[j], qr.Coef[j])
+		}
+	}
+	// a weighted fit differs from the unweighted one
+	plain, _ := qrSolver{}.Fit(X, Y)
+	if math.Abs(plain.Intercept-qr.Intercept) < 1e-6 {
+		t.Errorf("the weights did not change the intercept %g", qr.Intercept)
+	}
+}